@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
-	"fmt"
 	"os"
 
 	"github.com/erjiaqing/PCIJudger2/pkg/pci15"
+	"github.com/erjiaqing/PCIJudger2/pkg/trace"
+	"github.com/erjiaqing/PCIJudger2/pkg/util"
 	"github.com/sirupsen/logrus"
 )
 
@@ -24,12 +26,31 @@ func init() {
 	flag.StringVar(&conf.Problem, "input", conf.Problem, "problem path")
 	flag.StringVar(&conf.LanguageStorage, "langconf", conf.LanguageStorage, "path to store languages")
 	flag.StringVar(&conf.SupportFiles, "assets", conf.SupportFiles, "path to place supporting files")
+	flag.StringVar(&traceOutput, "trace", "", "write the phase timing trace to this file")
+	flag.StringVar(&traceFormat, "trace.format", trace.FormatChrome, "format of the trace: chrome or otlp")
 }
 
+var (
+	traceOutput string
+	traceFormat string
+)
+
 func main() {
 	flag.Parse()
+	ctx, cancel := util.SignalContext(context.Background())
+	defer cancel()
+	var tr *trace.Trace
+	if traceOutput != "" {
+		tr = trace.New()
+		ctx = trace.NewContext(ctx, tr)
+	}
 	src := conf.Problem
-	res, err := pci15.BuildProblem(src, "", conf)
+	res, err := pci15.BuildProblem(ctx, src, "", conf)
+	if tr != nil {
+		if err := tr.WriteFile(traceOutput, traceFormat, "pci15-builder"); err != nil {
+			logrus.Errorf("Failed to write trace: %v", err)
+		}
+	}
 	if err != nil {
 		logrus.Fatalf("Failed to build problem: %v", err)
 	}
@@ -37,5 +58,5 @@ func main() {
 	if err != nil {
 		logrus.Fatalf("Failed to generate output: %v", err)
 	}
-	fmt.Printf(string(resjson))
+	os.Stdout.Write(resjson)
 }
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
-	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/erjiaqing/PCIJudger2/pkg/pci15"
+	"github.com/erjiaqing/PCIJudger2/pkg/util"
 	"github.com/sirupsen/logrus"
 )
 
@@ -19,6 +22,14 @@ var conf = &pci15.Config{
 	MaxJudgeThread:  1,
 }
 
+var (
+	suggestTimeLimit bool
+	coverageOutput   string
+	junitOutput      string
+	htmlOutput       string
+	timeLimitOpts    = pci15.DefaultTimeLimitOptions
+)
+
 func init() {
 	flag.StringVar(&conf.Tmp, "tempdir", conf.Tmp, "tempory directory")
 	flag.BoolVar(&conf.IsDocker, "docker", conf.IsDocker, "is running in docker?")
@@ -28,18 +39,78 @@ func init() {
 	flag.StringVar(&conf.SupportFiles, "assets", conf.SupportFiles, "path to place supporting files")
 	flag.IntVar(&conf.MaxJudgeThread, "thread", conf.MaxJudgeThread, "code language")
 	flag.StringVar(&conf.MirrorFSConfig, "mirrorfsconf", conf.MirrorFSConfig, "path to mirrorfs config")
+	flag.StringVar(&coverageOutput, "coverage", "", "write which tests fail which wrong solutions as a table to this file, - for stderr")
+	flag.StringVar(&junitOutput, "junit", "", "write the result as JUnit XML to this file")
+	flag.StringVar(&htmlOutput, "html", "", "write the result as an HTML page to this file")
+	flag.BoolVar(&suggestTimeLimit, "tl", false, "suggest a time limit from the AC and TLE test solutions")
+	flag.IntVar(&timeLimitOpts.Runs, "tl.runs", timeLimitOpts.Runs, "judgements of every solution measured")
+	flag.Float64Var(&timeLimitOpts.Multiplier, "tl.multiplier", timeLimitOpts.Multiplier, "suggested time limit over the slowest AC time")
+	flag.Uint64Var(&timeLimitOpts.Round, "tl.round", timeLimitOpts.Round, "ms the suggested time limit is rounded up to")
+	flag.Uint64Var(&timeLimitOpts.Measure, "tl.measure", timeLimitOpts.Measure, "time limit in ms solutions are measured with, 3 times the one of the problem if 0")
 }
 
 func main() {
 	flag.Parse()
+	ctx, cancel := util.SignalContext(context.Background())
+	defer cancel()
 	src := conf.Problem
-	res, err := pci15.CheckProblemRepo(conf, src)
+	res, err := pci15.CheckProblemRepo(ctx, conf, src)
 	if err != nil {
-		logrus.Fatalf("Failed to build problem: %v", err)
+		if res == nil {
+			logrus.Fatalf("Failed to build problem: %v", err)
+		}
+		// reports still show the build log
+		logrus.Errorf("Failed to build problem: %v", err)
+	} else if suggestTimeLimit {
+		res.TimeLimit, err = pci15.SuggestTimeLimit(ctx, conf, src, &timeLimitOpts)
+		if err != nil {
+			logrus.Fatalf("Failed to suggest time limit: %v", err)
+		}
+		if !res.TimeLimit.Safe {
+			res.Success = false
+		}
+	}
+	if coverageOutput != "" && res.Coverage != nil {
+		if err := writeCoverage(coverageOutput, res.Coverage); err != nil {
+			logrus.Errorf("Failed to write coverage: %v", err)
+		}
+	}
+	name := filepath.Base(src)
+	if junitOutput != "" {
+		if err := writeReport(junitOutput, func(w io.Writer) error { return res.WriteJUnit(w, name) }); err != nil {
+			logrus.Errorf("Failed to write JUnit report: %v", err)
+		}
+	}
+	if htmlOutput != "" {
+		if err := writeReport(htmlOutput, func(w io.Writer) error { return res.WriteHTML(w, name) }); err != nil {
+			logrus.Errorf("Failed to write HTML report: %v", err)
+		}
 	}
 	resjson, err := json.MarshalIndent(res, "  ", "  ")
 	if err != nil {
 		logrus.Fatalf("Failed to generate output: %v", err)
 	}
-	fmt.Printf(string(resjson))
+	os.Stdout.Write(resjson)
+	if !res.Success {
+		os.Exit(1)
+	}
+}
+
+func writeCoverage(path string, coverage *pci15.Coverage) error {
+	if path == "-" {
+		return coverage.WriteTable(os.Stderr)
+	}
+	return writeReport(path, coverage.WriteTable)
+}
+
+func writeReport(path string, write func(io.Writer) error) error {
+	fp, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(fp); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
 }
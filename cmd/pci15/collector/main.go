@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/collector"
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn"
+	"github.com/erjiaqing/PCIJudger2/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+var conf = &collector.Config{
+	Timeout: time.Minute,
+	Expire:  24 * time.Hour,
+}
+
+var (
+	listenUDP  string
+	listenTCP  string
+	listenUnix string
+	listenHTTP string
+)
+
+func init() {
+	flag.StringVar(&listenUDP, "udp", "", "receive udp messages on this address")
+	flag.StringVar(&listenTCP, "tcp", "", "receive tcp messages on this address")
+	flag.StringVar(&listenUnix, "unix", "", "receive messages on this unix socket")
+	flag.StringVar(&listenHTTP, "http", "127.0.0.1:8081", "serve judge state and webhooks on this address")
+	flag.DurationVar(&conf.Timeout, "timeout", conf.Timeout, "mark judges silent after this long without messages")
+	flag.DurationVar(&conf.Expire, "expire", conf.Expire, "forget judges after this long without messages")
+	flag.DurationVar(&conf.MaxSkew, "maxskew", 5*time.Minute, "accepted clock difference of signed messages")
+}
+
+func main() {
+	flag.Parse()
+	ctx, cancel := util.SignalContext(context.Background())
+	defer cancel()
+
+	conf.Key = hostconn.KeyFromEnv()
+	if len(conf.Key) == 0 {
+		logrus.Warnf("%s is not set, accepting unsigned messages", hostconn.KeyEnv)
+	}
+	c := collector.New(conf)
+	defer c.Close()
+	if listenUDP != "" {
+		addr, err := c.ListenUDP(listenUDP)
+		if err != nil {
+			logrus.Fatalf("Failed to listen on udp %s: %v", listenUDP, err)
+		}
+		logrus.Infof("Receiving udp on %v", addr)
+	}
+	if listenTCP != "" {
+		addr, err := c.ListenStream("tcp", listenTCP)
+		if err != nil {
+			logrus.Fatalf("Failed to listen on tcp %s: %v", listenTCP, err)
+		}
+		logrus.Infof("Receiving tcp on %v", addr)
+	}
+	if listenUnix != "" {
+		addr, err := c.ListenStream("unix", listenUnix)
+		if err != nil {
+			logrus.Fatalf("Failed to listen on %s: %v", listenUnix, err)
+		}
+		logrus.Infof("Receiving on %v", addr)
+	}
+
+	srv := &http.Server{
+		Addr:    listenHTTP,
+		Handler: c.Handler(),
+	}
+	go func() {
+		logrus.Infof("Serving http on %s", listenHTTP)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.Fatalf("Failed to serve: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	srv.Shutdown(shutdownCtx)
+}
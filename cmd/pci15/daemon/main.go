@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/artifact"
+	"github.com/erjiaqing/PCIJudger2/pkg/daemon"
+	"github.com/erjiaqing/PCIJudger2/pkg/judgerpc"
+	"github.com/erjiaqing/PCIJudger2/pkg/pci15"
+	"github.com/erjiaqing/PCIJudger2/pkg/util"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+var conf = &pci15.Config{
+	Tmp:             os.TempDir(),
+	LanguageStorage: "/language",
+	ProblemPath:     "/problems",
+	SupportFiles:    "/assets",
+	MirrorFSConfig:  "/.mirrorfs.conf",
+	MaxJudgeThread:  1,
+}
+
+var daemonConf = &daemon.Config{
+	Judge:     conf,
+	Workers:   1,
+	QueueSize: 64,
+}
+
+var (
+	listen       string
+	listenGRPC   string
+	listenAdmin  string
+	drainTimeout time.Duration
+)
+
+func init() {
+	flag.StringVar(&conf.Tmp, "tempdir", conf.Tmp, "tempory directory")
+	flag.StringVar(&conf.LanguageStorage, "langconf", conf.LanguageStorage, "path to store languages")
+	flag.StringVar(&conf.ProblemPath, "datapath", conf.ProblemPath, "path to store problem checkouts")
+	flag.StringVar(&conf.MirrorFSConfig, "mirrorfsconf", conf.MirrorFSConfig, "path to mirrorfs config")
+	flag.StringVar(&conf.SupportFiles, "assets", conf.SupportFiles, "path to place supporting files")
+	flag.IntVar(&conf.MaxJudgeThread, "thread", conf.MaxJudgeThread, "threads per judgement")
+	flag.StringVar(&conf.ArtifactDir, "artifacts", "", "directory to keep complete test outputs in")
+	flag.StringVar((*string)(&conf.ArtifactPolicy), "artifacts.policy", string(artifact.PolicyFailed), "tests to keep outputs of: none, failed or all")
+	flag.Int64Var(&conf.ArtifactMaxSize, "artifacts.maxsize", artifact.DefaultMaxSize, "bytes kept of every output")
+	flag.DurationVar(&conf.ArtifactTTL, "artifacts.ttl", 7*24*time.Hour, "remove kept outputs after this long, 0 to keep them")
+	flag.StringVar((*string)(&conf.Feedback), "feedback", "", "feedback policy overriding the one of problems: full, examples or first_failure")
+	flag.BoolVar(&conf.Trace, "trace", false, "embed phase timing traces in results")
+	flag.IntVar(&daemonConf.Workers, "workers", daemonConf.Workers, "judgements running at the same time")
+	flag.IntVar(&daemonConf.QueueSize, "queue", daemonConf.QueueSize, "max queued submissions")
+	flag.StringVar(&daemonConf.ProblemGit, "git", "", "git url of problems, %s is replaced by problem id")
+	flag.IntVar(&daemonConf.ProblemVersions, "git.versions", 3, "checkouts kept of every problem")
+	flag.DurationVar(&daemonConf.RecordTTL, "records.ttl", time.Hour, "forget finished submissions after this long")
+	flag.IntVar(&daemonConf.MaxRecords, "records.max", 10000, "max submissions remembered")
+	flag.StringVar(&listen, "listen", "127.0.0.1:8080", "address of the http api")
+	flag.StringVar(&listenGRPC, "grpc", "", "address of the grpc api, empty to disable")
+	flag.StringVar(&listenAdmin, "admin", "", "address of the admin api serving unredacted results, empty to disable; keep it private")
+	flag.DurationVar(&drainTimeout, "drain", 10*time.Minute, "max time to finish queued submissions on SIGTERM")
+}
+
+func main() {
+	flag.Parse()
+	ctx, cancel := util.SignalContext(context.Background())
+	defer cancel()
+	if conf.MaxJudgeThread <= 0 {
+		conf.MaxJudgeThread = 1
+	}
+	if err := os.MkdirAll(conf.ProblemPath, 0755); err != nil {
+		logrus.Fatalf("Failed to create problem path: %v", err)
+	}
+	if _, err := artifact.ParsePolicy(string(conf.ArtifactPolicy)); err != nil {
+		logrus.Fatalf("%v", err)
+	}
+	if _, err := pci15.ParseFeedbackPolicy(string(conf.Feedback)); err != nil {
+		logrus.Fatalf("%v", err)
+	}
+	if store, err := conf.ArtifactStore(); err != nil {
+		logrus.Fatalf("Failed to open artifact store: %v", err)
+	} else if store != nil && store.TTL > 0 {
+		go store.CleanupLoop(store.TTL/10, ctx.Done())
+	}
+
+	pool := daemon.NewPool(daemonConf)
+	pool.Start()
+
+	srv := &http.Server{
+		Addr:    listen,
+		Handler: daemon.Handler(pool),
+	}
+	go func() {
+		logrus.Infof("Listening on %s", listen)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.Fatalf("Failed to serve: %v", err)
+		}
+	}()
+
+	var adminSrv *http.Server
+	if listenAdmin != "" {
+		adminSrv = &http.Server{
+			Addr:    listenAdmin,
+			Handler: daemon.AdminHandler(pool),
+		}
+		go func() {
+			logrus.Infof("Serving admin api on %s", listenAdmin)
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logrus.Fatalf("Failed to serve admin api: %v", err)
+			}
+		}()
+	}
+
+	var grpcSrv *grpc.Server
+	if listenGRPC != "" {
+		lis, err := net.Listen("tcp", listenGRPC)
+		if err != nil {
+			logrus.Fatalf("Failed to listen on %s: %v", listenGRPC, err)
+		}
+		grpcSrv = grpc.NewServer()
+		judgerpc.RegisterJudgeServer(grpcSrv, judgerpc.NewServer(pool))
+		go func() {
+			logrus.Infof("Serving grpc on %s", listenGRPC)
+			if err := grpcSrv.Serve(lis); err != nil {
+				logrus.Fatalf("Failed to serve grpc: %v", err)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	logrus.Infof("Draining...")
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer drainCancel()
+	if err := pool.Shutdown(drainCtx); err != nil {
+		logrus.Warnf("Failed to drain: %v", err)
+	}
+	if err := srv.Shutdown(drainCtx); err != nil {
+		logrus.Warnf("Failed to shut down http server: %v", err)
+	}
+	if adminSrv != nil {
+		if err := adminSrv.Shutdown(drainCtx); err != nil {
+			logrus.Warnf("Failed to shut down admin server: %v", err)
+		}
+	}
+	if grpcSrv != nil {
+		grpcSrv.GracefulStop()
+	}
+}
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"time"
 
+	"github.com/erjiaqing/PCIJudger2/pkg/artifact"
 	"github.com/erjiaqing/PCIJudger2/pkg/hostconn"
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn/message"
 
+	"github.com/erjiaqing/PCIJudger2/pkg/metrics"
 	"github.com/erjiaqing/PCIJudger2/pkg/pci15"
+	"github.com/erjiaqing/PCIJudger2/pkg/trace"
+	"github.com/erjiaqing/PCIJudger2/pkg/util"
 	"github.com/sirupsen/logrus"
 )
 
@@ -28,10 +36,22 @@ var code = &pci15.SourceCode{
 	Source:   "/code",
 }
 
+var customRun = &pci15.CustomRun{}
+
+var hackInput string
+
 var (
 	hostUDPConnIP   string
 	hostUDPConnPort int
 	judgeUid        string
+	eventsOutput    string
+	hostURL         string
+	metricsOutput   string
+	logOutput       string
+	adminOutput     string
+	traceOutput     string
+	traceFormat     string
+	hostOpts        = &hostconn.Options{}
 )
 
 func init() {
@@ -41,29 +61,196 @@ func init() {
 	flag.StringVar(&conf.LanguageStorage, "langconf", conf.LanguageStorage, "path to store languages")
 	flag.StringVar(&conf.ProblemPath, "output", conf.ProblemPath, "path to output problem")
 	flag.StringVar(&conf.MirrorFSConfig, "mirrorfsconf", conf.MirrorFSConfig, "path to mirrorfs config")
-	flag.StringVar(&code.Source, "source", code.Source, "source code")
+	flag.StringVar(&code.Source, "source", code.Source, "source code, or a zip or tar archive of a project")
 	flag.StringVar(&code.Language, "language", code.Language, "code language")
 	flag.StringVar(&hostUDPConnIP, "udp.ip", "", "host ip")
 	flag.StringVar(&judgeUid, "udp.uid", "", "judge id")
 	flag.StringVar(&conf.SupportFiles, "assets", conf.SupportFiles, "path to place supporting files")
 	flag.IntVar(&hostUDPConnPort, "udp.port", 0, "host port")
 	flag.IntVar(&conf.MaxJudgeThread, "thread", conf.MaxJudgeThread, "code language")
+	flag.StringVar(&eventsOutput, "events", "", "write judge events as json lines to this file")
+	flag.StringVar(&hostURL, "host", "", "report to host: udp://ip:port, tcp://ip:port, unix:///path or http(s) webhook url")
+	flag.BoolVar(&hostOpts.Ack, "host.ack", false, "wait for the host to acknowledge every message")
+	flag.IntVar(&hostOpts.Retries, "host.retries", 5, "resends of unacknowledged messages")
+	flag.StringVar(&traceOutput, "trace", "", "write the phase timing trace to this file")
+	flag.StringVar(&traceFormat, "trace.format", trace.FormatChrome, "format of the trace: chrome or otlp")
+	flag.BoolVar(&conf.Trace, "trace.embed", false, "embed the phase timing trace in the result")
+	flag.StringVar(&conf.ArtifactDir, "artifacts", "", "directory to keep complete test outputs in")
+	flag.StringVar((*string)(&conf.ArtifactPolicy), "artifacts.policy", string(artifact.PolicyFailed), "tests to keep outputs of: none, failed or all")
+	flag.Int64Var(&conf.ArtifactMaxSize, "artifacts.maxsize", artifact.DefaultMaxSize, "bytes kept of every output")
+	flag.DurationVar(&conf.ArtifactTTL, "artifacts.ttl", 7*24*time.Hour, "remove kept outputs after this long, 0 to keep them")
+	flag.StringVar((*string)(&conf.Feedback), "feedback", "", "feedback policy overriding the one of problems: full, examples or first_failure")
+	flag.StringVar(&adminOutput, "admin", "", "write the result with nothing hidden by the feedback policy to this file")
+	flag.StringVar(&hackInput, "hack", "", "hack the code with this input, validated and answered by the problem")
+	flag.StringVar(&customRun.Input, "custom", "", "run the code on this input instead of judging it")
+	flag.StringVar(&customRun.Expected, "custom.expected", "", "expected output of the custom run")
+	flag.StringVar(&customRun.Checker, "custom.checker", "!diff", "builtin checker comparing the custom run with the expected output")
+	flag.Uint64Var(&customRun.TimeLimit, "custom.timelimit", 1000, "time limit of the custom run in ms")
+	flag.Uint64Var(&customRun.MemoryLimit, "custom.memorylimit", 256, "memory limit of the custom run in MiB")
+	flag.Int64Var(&customRun.OutputLimit, "custom.outputlimit", 64<<10, "bytes of stdout and stderr returned by the custom run")
+	flag.Int64Var(&conf.ArchiveMaxSize, "archive.maxsize", 16<<20, "bytes of an archive submission")
+	flag.IntVar(&conf.ArchiveMaxFiles, "archive.maxfiles", 1000, "files and directories unpacked from an archive submission")
+	flag.Int64Var(&conf.ArchiveMaxUnpacked, "archive.maxunpacked", 64<<20, "bytes unpacked from an archive submission")
+	flag.StringVar(&logOutput, "log", "", "write the judge log as json lines to this file")
+	flag.StringVar(&metricsOutput, "metrics", "", "dump metrics to this file when done")
+	flag.DurationVar(&hostOpts.Heartbeat, "host.heartbeat", 0, "interval of heartbeats to host, 0 to disable")
+}
+
+func dialHost() (hostconn.Reporter, error) {
+	hostOpts.Uid = judgeUid
+	hostOpts.Key = []byte(conf.HostKey)
+	if len(hostOpts.Key) == 0 {
+		hostOpts.Key = hostconn.KeyFromEnv()
+	}
+	if hostURL != "" {
+		return hostconn.Dial(hostURL, hostOpts)
+	}
+	if hostUDPConnIP != "" && hostUDPConnPort != 0 {
+		return hostconn.NewUDP(fmt.Sprintf("%s:%d", hostUDPConnIP, hostUDPConnPort), hostOpts)
+	}
+	return nil, nil
 }
 
 func main() {
 	flag.Parse()
-	conf.HostSocket = hostconn.NewUDP(hostUDPConnIP, hostUDPConnPort, judgeUid)
+	ctx, cancel := util.SignalContext(context.Background())
+	defer cancel()
+	if host, err := dialHost(); err != nil {
+		fatalf("Failed to connect to host: %v", err)
+	} else if host != nil {
+		conf.HostSocket = host
+		defer host.Close()
+	}
 	if conf.MaxJudgeThread <= 0 {
 		conf.MaxJudgeThread = 1
 	}
-	res, err := pci15.Judge(conf, code, conf.Problem)
+	if _, err := artifact.ParsePolicy(string(conf.ArtifactPolicy)); err != nil {
+		fatalf("%v", err)
+	}
+	if _, err := pci15.ParseFeedbackPolicy(string(conf.Feedback)); err != nil {
+		fatalf("%v", err)
+	}
+	if store, err := conf.ArtifactStore(); err != nil {
+		fatalf("Failed to open artifact store: %v", err)
+	} else if store != nil {
+		if _, err := store.Cleanup(); err != nil {
+			logrus.Warnf("Failed to clean up artifacts: %v", err)
+		}
+	}
+	if eventsOutput == "-" {
+		// stdout carries the result
+		fatalf("-events needs a file, not stdout")
+	} else if eventsOutput != "" {
+		fp, err := os.OpenFile(eventsOutput, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			fatalf("Failed to open events output: %v", err)
+		}
+		defer fp.Close()
+		conf.Events = pci15.NewJSONLinesSink(fp)
+	}
+	var tr *trace.Trace
+	if traceOutput != "" {
+		tr = trace.New()
+		ctx = trace.NewContext(ctx, tr)
+	}
+	if hackInput != "" {
+		res, err := pci15.Hack(ctx, conf, code, conf.Problem, hackInput)
+		writeDiagnostics(tr)
+		if err != nil {
+			fatalf("Failed to hack: %v", err)
+		}
+		resjson, err := json.MarshalIndent(res, "  ", "  ")
+		if err != nil {
+			fatalf("Failed to generate output: %v", err)
+		}
+		os.Stdout.Write(resjson)
+		return
+	}
+	if customRun.Input != "" {
+		res, err := pci15.RunCustom(ctx, conf, code, customRun)
+		writeDiagnostics(tr)
+		if err != nil {
+			fatalf("Failed to run code: %v", err)
+		}
+		resjson, err := json.MarshalIndent(res, "  ", "  ")
+		if err != nil {
+			fatalf("Failed to generate output: %v", err)
+		}
+		os.Stdout.Write(resjson)
+		return
+	}
+	res, err := pci15.Judge(ctx, conf, code, conf.Problem)
+	writeDiagnostics(tr)
 	if err != nil {
-		logrus.Fatalf("Failed to judge code: %v", err)
+		fatalf("Failed to judge code: %v", err)
+	}
+	if adminOutput != "" {
+		if err := writeJSON(adminOutput, res.Unredacted()); err != nil {
+			logrus.Errorf("Failed to write admin result: %v", err)
+		}
+	}
+	if logOutput != "" && res.Unredacted().Log != nil {
+		if err := writeLog(logOutput, res.Unredacted().Log); err != nil {
+			logrus.Errorf("Failed to write judge log: %v", err)
+		}
 	}
 	resjson, err := json.MarshalIndent(res, "  ", "  ")
 	if err != nil {
-		logrus.Fatalf("Failed to generate output: %v", err)
+		fatalf("Failed to generate output: %v", err)
+	}
+	os.Stdout.Write(resjson)
+	if conf.HostSocket != nil {
+		if err := conf.HostSocket.SendResult(resjson); err != nil {
+			logrus.Errorf("Failed to deliver result to host: %v", err)
+			conf.HostSocket.Close()
+			os.Exit(1)
+		}
+	}
+}
+
+// fatalf logs the error, reports the judgement failed to the host and exits.
+// The host connection is closed first, os.Exit skips deferred calls and the
+// queued messages would be lost.
+func fatalf(format string, args ...interface{}) {
+	logrus.Errorf(format, args...)
+	if conf.HostSocket != nil {
+		conf.HostSocket.SendStatus(&hostconn.Status{
+			Phase:    message.Phase_FAILED,
+			Progress: 100,
+			Verdict:  "SE",
+		})
+		conf.HostSocket.Close()
+	}
+	os.Exit(1)
+}
+
+func writeLog(path string, log *pci15.PCILog) error {
+	fp, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	return log.WriteJSONLines(fp)
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// writeDiagnostics writes the trace and metrics files asked for.
+func writeDiagnostics(tr *trace.Trace) {
+	if tr != nil {
+		if err := tr.WriteFile(traceOutput, traceFormat, "pci15-judger"); err != nil {
+			logrus.Errorf("Failed to write trace: %v", err)
+		}
+	}
+	if metricsOutput != "" {
+		if err := metrics.Default.WriteFile(metricsOutput); err != nil {
+			logrus.Errorf("Failed to dump metrics: %v", err)
+		}
 	}
-	conf.HostSocket.SendStatus("FF", 100)
-	fmt.Printf(string(resjson))
 }
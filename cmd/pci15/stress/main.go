@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/pci15"
+	"github.com/erjiaqing/PCIJudger2/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+var conf = &pci15.Config{
+	Tmp:             os.TempDir(),
+	Problem:         "/input",
+	LanguageStorage: "/language",
+	SupportFiles:    "/assets",
+	MirrorFSConfig:  ".mirrorfs.conf",
+	MaxJudgeThread:  1,
+}
+
+var opts = &pci15.StressOptions{
+	Generator: &pci15.SourceCode{},
+	Trusted:   &pci15.SourceCode{},
+	Suspect:   &pci15.SourceCode{},
+}
+
+func init() {
+	flag.StringVar(&conf.Tmp, "tempdir", conf.Tmp, "tempory directory")
+	flag.BoolVar(&conf.IsDocker, "docker", conf.IsDocker, "is running in docker?")
+	flag.StringVar(&conf.Problem, "problem", conf.Problem, "problem path")
+	flag.StringVar(&conf.LanguageStorage, "langconf", conf.LanguageStorage, "path to store languages")
+	flag.StringVar(&conf.SupportFiles, "assets", conf.SupportFiles, "path to place supporting files")
+	flag.StringVar(&conf.MirrorFSConfig, "mirrorfsconf", conf.MirrorFSConfig, "path to mirrorfs config")
+	flag.StringVar(&opts.Generator.Source, "gen", "", "generator source, run with the seed as its only argument")
+	flag.StringVar(&opts.Generator.Language, "gen.lang", "", "generator language")
+	flag.StringVar(&opts.Trusted.Source, "trusted", "", "trusted solution source")
+	flag.StringVar(&opts.Trusted.Language, "trusted.lang", "", "trusted solution language")
+	flag.StringVar(&opts.Suspect.Source, "suspect", "", "suspect solution source")
+	flag.StringVar(&opts.Suspect.Language, "suspect.lang", "", "suspect solution language")
+	flag.Int64Var(&opts.Seed, "seed", 1, "first seed")
+	flag.IntVar(&opts.Count, "count", 100, "seeds to try")
+	flag.StringVar(&opts.Checker, "checker", "", "builtin checker, like !lcmp, used instead of the one of the problem")
+	flag.StringVar(&opts.SaveDir, "save", "", "directory to save the failing test in, stress/ of the problem by default")
+}
+
+func main() {
+	flag.Parse()
+	ctx, cancel := util.SignalContext(context.Background())
+	defer cancel()
+	res, err := pci15.Stress(ctx, conf, conf.Problem, opts)
+	if err != nil {
+		logrus.Fatalf("Failed to stress test: %v", err)
+	}
+	resjson, err := json.MarshalIndent(res, "  ", "  ")
+	if err != nil {
+		logrus.Fatalf("Failed to generate output: %v", err)
+	}
+	os.Stdout.Write(resjson)
+	if res.Found {
+		os.Exit(1)
+	}
+}
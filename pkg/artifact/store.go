@@ -0,0 +1,187 @@
+// Package artifact keeps the complete outputs of test runs, content addressed
+// by sha256, for a limited time.
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type Policy string
+
+const (
+	PolicyNone   Policy = "none"
+	PolicyFailed Policy = "failed"
+	PolicyAll    Policy = "all"
+)
+
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(s) {
+	case "", PolicyNone:
+		return PolicyNone, nil
+	case PolicyFailed, PolicyAll:
+		return Policy(s), nil
+	}
+	return PolicyNone, fmt.Errorf("unknown artifact policy %s, expected none, failed or all", s)
+}
+
+// Keep reports whether the artifacts of a test with verdict are kept.
+func (p Policy) Keep(verdict string) bool {
+	switch p {
+	case PolicyAll:
+		return true
+	case PolicyFailed:
+		return verdict != "AC"
+	}
+	return false
+}
+
+// DefaultMaxSize is the cap of an artifact when none is configured.
+const DefaultMaxSize = 16 << 20
+
+type Artifact struct {
+	Name      string `json:"name"`
+	Hash      string `json:"hash"`
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+type Store struct {
+	Dir     string
+	MaxSize int64         // bytes kept of every artifact
+	TTL     time.Duration // artifacts unused for this long are removed, 0 keeps them
+}
+
+func New(dir string, maxSize int64, ttl time.Duration) (*Store, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	return &Store{
+		Dir:     dir,
+		MaxSize: maxSize,
+		TTL:     ttl,
+	}, nil
+}
+
+func (s *Store) path(hash string) string {
+	return filepath.Join(s.Dir, hash[:2], hash)
+}
+
+// Put stores the first MaxSize bytes of file src. Storing content already in
+// the store only renews its TTL.
+func (s *Store) Put(name, src string) (*Artifact, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	stat, err := in.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempFile(s.Dir, ".put")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(in, s.MaxSize))
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	dest := s.path(hash)
+	if _, err := os.Stat(dest); err == nil {
+		now := time.Now()
+		if err := os.Chtimes(dest, now, now); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(tmp.Name(), dest); err != nil {
+			return nil, err
+		}
+	}
+	return &Artifact{
+		Name:      name,
+		Hash:      hash,
+		Path:      dest,
+		Size:      size,
+		Truncated: stat.Size() > size,
+	}, nil
+}
+
+func (s *Store) Open(hash string) (*os.File, error) {
+	if len(hash) != sha256.Size*2 {
+		return nil, fmt.Errorf("bad artifact hash %s", hash)
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return nil, fmt.Errorf("bad artifact hash %s", hash)
+	}
+	return os.Open(s.path(hash))
+}
+
+// Cleanup removes the artifacts older than TTL, it returns how many were.
+func (s *Store) Cleanup() (int, error) {
+	if s.TTL <= 0 {
+		return 0, nil
+	}
+	deadline := time.Now().Add(-s.TTL)
+	removed := 0
+	err := filepath.Walk(s.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !info.ModTime().Before(deadline) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// CleanupLoop runs Cleanup every interval until stop is closed.
+func (s *Store) CleanupLoop(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.Cleanup(); err != nil {
+			logrus.Warnf("Failed to clean up artifacts: %v", err)
+		} else if n > 0 {
+			logrus.Infof("Removed %d expired artifacts", n)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		keepAC bool
+		keepWA bool
+		ok     bool
+	}{
+		{"", false, false, true},
+		{"none", false, false, true},
+		{"failed", false, true, true},
+		{"all", true, true, true},
+		{"some", false, false, false},
+	}
+	for _, tt := range tests {
+		p, err := ParsePolicy(tt.policy)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: got error %v", tt.policy, err)
+		}
+		if p.Keep("AC") != tt.keepAC || p.Keep("WA") != tt.keepWA {
+			t.Errorf("%q: keeps AC %v and WA %v", tt.policy, p.Keep("AC"), p.Keep("WA"))
+		}
+	}
+}
+
+func TestPut(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "artifact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	s, err := New(filepath.Join(tmp, "store"), 8, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		content   string
+		stored    string
+		truncated bool
+	}{
+		{"empty", "", "", false},
+		{"small", "1 2 3\n", "1 2 3\n", false},
+		{"max size", "12345678", "12345678", false},
+		{"larger than max size", "1234567890", "12345678", true},
+	}
+	for _, tt := range tests {
+		src := filepath.Join(tmp, "output")
+		if err := ioutil.WriteFile(src, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		a, err := s.Put("out", src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		sum := sha256.Sum256([]byte(tt.stored))
+		if a.Hash != hex.EncodeToString(sum[:]) || a.Size != int64(len(tt.stored)) || a.Truncated != tt.truncated {
+			t.Errorf("%s: got hash %s, size %d, truncated %v", tt.name, a.Hash, a.Size, a.Truncated)
+		}
+		got, err := ioutil.ReadFile(a.Path)
+		if err != nil || string(got) != tt.stored {
+			t.Errorf("%s: stored %q, %v, want %q", tt.name, got, err, tt.stored)
+		}
+	}
+
+	// temporary files are removed
+	files, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), ".put") {
+			t.Errorf("temporary file %s was left", f.Name())
+		}
+	}
+}
+
+func TestPutRenewsAndCleanup(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "artifact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	s, err := New(filepath.Join(tmp, "store"), 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(content string) *Artifact {
+		src := filepath.Join(tmp, "output")
+		if err := ioutil.WriteFile(src, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		a, err := s.Put("out", src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	expire := func(a *Artifact) {
+		old := time.Now().Add(-2 * time.Hour)
+		if err := os.Chtimes(a.Path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	renewed, expired, fresh := put("renewed"), put("expired"), put("fresh")
+	expire(renewed)
+	expire(expired)
+	// storing the same content again renews it
+	if again := put("renewed"); again.Path != renewed.Path {
+		t.Errorf("same content stored at %s and %s", renewed.Path, again.Path)
+	}
+
+	n, err := s.Cleanup()
+	if err != nil || n != 1 {
+		t.Errorf("removed %d, %v, want 1", n, err)
+	}
+	tests := []struct {
+		name string
+		a    *Artifact
+		kept bool
+	}{
+		{"renewed", renewed, true},
+		{"expired", expired, false},
+		{"fresh", fresh, true},
+	}
+	for _, tt := range tests {
+		if _, err := os.Stat(tt.a.Path); (err == nil) != tt.kept {
+			t.Errorf("%s: kept %v, want %v", tt.name, err == nil, tt.kept)
+		}
+	}
+
+	// no TTL keeps everything
+	s.TTL = 0
+	expire(fresh)
+	if n, err := s.Cleanup(); err != nil || n != 0 {
+		t.Errorf("removed %d, %v without a TTL", n, err)
+	}
+}
@@ -0,0 +1,239 @@
+// Package collector is the receiving side of hostconn: it decodes the
+// messages of any number of judges and keeps track of their state.
+package collector
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn"
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn/message"
+	"github.com/golang/protobuf/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// Judge is the state of one judge as seen by the collector.
+type Judge struct {
+	hostconn.Machine
+	LastSeen  time.Time `json:"last_seen"`
+	Silent    bool      `json:"silent"`
+	Messages  int       `json:"messages"`
+	Rejected  int       `json:"rejected"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+type Config struct {
+	// Timeout marks a judge silent when it sent nothing for this long and has
+	// not finished, heartbeats keep a judge alive.
+	Timeout time.Duration
+	// Expire drops judges this long after their last message, 0 keeps them.
+	Expire time.Duration
+	// Key, when set, makes the collector drop messages not signed with it
+	// and sign its acks.
+	Key []byte
+	// MaxSkew is the accepted difference between message timestamps and now.
+	MaxSkew time.Duration
+}
+
+type Collector struct {
+	conf     Config
+	verifier *Verifier
+
+	mut     sync.RWMutex
+	judges  map[string]*Judge
+	notify  chan struct{}
+	closers []func() error
+	stop    chan struct{}
+
+	now func() time.Time
+}
+
+func New(conf *Config) *Collector {
+	c := &Collector{
+		judges: make(map[string]*Judge),
+		notify: make(chan struct{}),
+		stop:   make(chan struct{}),
+		now:    time.Now,
+	}
+	if conf != nil {
+		c.conf = *conf
+	}
+	if len(c.conf.Key) > 0 {
+		c.verifier = NewVerifier(c.conf.Key, c.conf.MaxSkew)
+	}
+	if c.conf.Timeout <= 0 {
+		c.conf.Timeout = time.Minute
+	}
+	if c.conf.Expire > 0 {
+		go c.expire()
+	}
+	return c
+}
+
+// Handle decodes one message and returns the ack to send back. Stale and
+// rejected messages are acknowledged too, so that the judge stops resending,
+// but messages failing verification are not, and a nil ack is returned.
+func (c *Collector) Handle(data []byte) (*message.Ack, error) {
+	msg, err := hostconn.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	ack := &message.Ack{
+		Uid: msg.Uid,
+		Seq: msg.Seq,
+	}
+	if c.verifier != nil {
+		err := c.verifier.Verify(msg)
+		if err != nil && err != ErrReplay {
+			logrus.Warnf("Dropping message %d of %s: %v", msg.Seq, msg.Uid, err)
+			return nil, err
+		}
+		hostconn.SignAck(ack, c.conf.Key)
+		if err == ErrReplay {
+			return ack, nil
+		}
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	j, ok := c.judges[msg.Uid]
+	if !ok {
+		j = &Judge{Machine: *hostconn.NewMachine(msg.Uid)}
+		c.judges[msg.Uid] = j
+	}
+	j.LastSeen = c.now()
+	j.Messages++
+	if err := j.Apply(msg); err == hostconn.ErrStale {
+		return ack, nil
+	} else if err != nil {
+		j.Rejected++
+		j.LastError = err.Error()
+		logrus.Warnf("Rejected message %d of %s: %v", msg.Seq, msg.Uid, err)
+		return ack, err
+	}
+	close(c.notify)
+	c.notify = make(chan struct{})
+	return ack, nil
+}
+
+func (c *Collector) snapshot(j *Judge, now time.Time) *Judge {
+	ret := *j
+	ret.Silent = !hostconn.Terminal(j.Phase) && now.Sub(j.LastSeen) > c.conf.Timeout
+	return &ret
+}
+
+// Get returns a copy of the state of judge uid.
+func (c *Collector) Get(uid string) (*Judge, bool) {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	j, ok := c.judges[uid]
+	if !ok {
+		return nil, false
+	}
+	return c.snapshot(j, c.now()), true
+}
+
+// List returns the state of all judges, ordered by uid.
+func (c *Collector) List() []*Judge {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	now := c.now()
+	ret := make([]*Judge, 0, len(c.judges))
+	for _, j := range c.judges {
+		ret = append(ret, c.snapshot(j, now))
+	}
+	sort.Slice(ret, func(a, b int) bool {
+		return ret[a].Uid < ret[b].Uid
+	})
+	return ret
+}
+
+// Wait blocks until cond holds for judge uid, or ctx is done. It is meant for
+// tests that run Judge against an in-process collector.
+func (c *Collector) Wait(ctx context.Context, uid string, cond func(j *Judge) bool) (*Judge, error) {
+	for {
+		c.mut.RLock()
+		notify := c.notify
+		j, ok := c.judges[uid]
+		if ok {
+			j = c.snapshot(j, c.now())
+		}
+		c.mut.RUnlock()
+		if ok && cond(j) {
+			return j, nil
+		}
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// WaitFinished waits until judge uid reached FINISHED or FAILED.
+func (c *Collector) WaitFinished(ctx context.Context, uid string) (*Judge, error) {
+	return c.Wait(ctx, uid, func(j *Judge) bool {
+		return hostconn.Terminal(j.Phase)
+	})
+}
+
+// Close stops all listeners.
+func (c *Collector) Close() error {
+	c.mut.Lock()
+	closers := c.closers
+	c.closers = nil
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+	c.mut.Unlock()
+	var ret error
+	for _, f := range closers {
+		if err := f(); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+func (c *Collector) addCloser(f func() error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.closers = append(c.closers, f)
+}
+
+func (c *Collector) expire() {
+	ticker := time.NewTicker(c.conf.Expire / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+		c.expireJudges(c.now())
+	}
+}
+
+// expireJudges drops the judges silent for longer than Expire at now.
+func (c *Collector) expireJudges(now time.Time) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	for uid, j := range c.judges {
+		if now.Sub(j.LastSeen) > c.conf.Expire {
+			delete(c.judges, uid)
+		}
+	}
+}
+
+func marshalAck(ack *message.Ack) []byte {
+	data, err := proto.Marshal(ack)
+	if err != nil {
+		logrus.Errorf("Failed to marshal ack: %v", err)
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,157 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn"
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn/message"
+	"github.com/golang/protobuf/proto"
+)
+
+var testKey = []byte("secret")
+
+func signed(t *testing.T, key []byte, msg *message.StateMessage) *message.StateMessage {
+	if err := hostconn.Sign(msg, key); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func encode(t *testing.T, msg *message.StateMessage) []byte {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	msg := func() *message.StateMessage {
+		return &message.StateMessage{Uid: "judge", Seq: 1, Phase: message.Phase_PREPARING}
+	}
+	replayed := signed(t, testKey, msg())
+	tests := []struct {
+		name string
+		msg  *message.StateMessage
+		now  time.Time
+		err  error
+	}{
+		{"valid", signed(t, testKey, msg()), now, nil},
+		{"unsigned", msg(), now, ErrBadSignature},
+		{"other key", signed(t, []byte("other"), msg()), now, ErrBadSignature},
+		{"tampered", func() *message.StateMessage {
+			m := signed(t, testKey, msg())
+			m.Phase = message.Phase_FINISHED
+			return m
+		}(), now, ErrBadSignature},
+		{"late", signed(t, testKey, msg()), now.Add(6 * time.Minute), ErrOutdated},
+		{"early", signed(t, testKey, msg()), now.Add(-6 * time.Minute), ErrOutdated},
+		{"first", replayed, now, nil},
+		{"replayed", replayed, now, ErrReplay},
+	}
+	v := NewVerifier(testKey, 5*time.Minute)
+	for _, tt := range tests {
+		v.now = func() time.Time { return tt.now }
+		if err := v.Verify(tt.msg); err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestHandleSigned(t *testing.T) {
+	c := New(&Config{Key: testKey})
+	defer c.Close()
+
+	if ack, err := c.Handle(encode(t, &message.StateMessage{Uid: "judge", Seq: 1, Phase: message.Phase_PREPARING})); ack != nil || err != ErrBadSignature {
+		t.Errorf("unsigned message: ack %v, error %v", ack, err)
+	}
+	if _, ok := c.Get("judge"); ok {
+		t.Errorf("unsigned message was applied")
+	}
+
+	data := encode(t, signed(t, testKey, &message.StateMessage{Uid: "judge", Seq: 1, Phase: message.Phase_PREPARING}))
+	for i := 0; i < 2; i++ {
+		ack, err := c.Handle(data)
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if ack.Uid != "judge" || ack.Seq != 1 || !hostconn.CheckAckSignature(ack, testKey) {
+			t.Errorf("message %d: bad ack %v", i, ack)
+		}
+	}
+	j, ok := c.Get("judge")
+	if !ok {
+		t.Fatal("signed message was not applied")
+	}
+	if j.Messages != 1 || j.Phase != message.Phase_PREPARING {
+		t.Errorf("replay was applied: %d messages, phase %v", j.Messages, j.Phase)
+	}
+}
+
+func TestHandleRejected(t *testing.T) {
+	c := New(nil)
+	defer c.Close()
+	send := func(seq uint64, phase message.Phase) (*message.Ack, error) {
+		return c.Handle(encode(t, &message.StateMessage{Uid: "judge", Seq: seq, Phase: phase}))
+	}
+	if _, err := send(1, message.Phase_RUNNING); err != nil {
+		t.Fatal(err)
+	}
+	// rejected and stale messages are acked all the same, so that the judge
+	// stops resending them
+	if ack, err := send(2, message.Phase_COMPILING); ack == nil || err == nil {
+		t.Errorf("going back: ack %v, error %v", ack, err)
+	}
+	if ack, err := send(1, message.Phase_RUNNING); ack == nil || err != nil {
+		t.Errorf("stale: ack %v, error %v", ack, err)
+	}
+	if _, err := c.Handle([]byte("garbage")); err == nil {
+		t.Errorf("garbage was decoded")
+	}
+	j, _ := c.Get("judge")
+	if j.Rejected != 1 || j.LastError == "" || j.Phase != message.Phase_RUNNING {
+		t.Errorf("got %d rejected, last error %q, phase %v", j.Rejected, j.LastError, j.Phase)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name    string
+		phase   message.Phase
+		after   time.Duration
+		silent  bool
+		expired bool
+	}{
+		{"recent", message.Phase_RUNNING, 30 * time.Second, false, false},
+		{"silent", message.Phase_RUNNING, 2 * time.Minute, true, false},
+		{"finished", message.Phase_FINISHED, 2 * time.Minute, false, false},
+		{"failed", message.Phase_FAILED, 2 * time.Minute, false, false},
+		{"expired", message.Phase_RUNNING, 11 * time.Minute, true, true},
+		{"expired finished", message.Phase_FINISHED, 11 * time.Minute, false, true},
+	}
+	for _, tt := range tests {
+		c := New(&Config{Timeout: time.Minute})
+		c.conf.Expire = 10 * time.Minute // not through New, that starts expiring
+		now := start
+		c.now = func() time.Time { return now }
+		if _, err := c.Handle(encode(t, &message.StateMessage{Uid: "judge", Seq: 1, Phase: tt.phase})); err != nil {
+			t.Fatal(err)
+		}
+		now = start.Add(tt.after)
+		j, _ := c.Get("judge")
+		if j.Silent != tt.silent {
+			t.Errorf("%s: silent %v, want %v", tt.name, j.Silent, tt.silent)
+		}
+		if list := c.List(); len(list) != 1 || list[0].Silent != tt.silent {
+			t.Errorf("%s: listed as %v", tt.name, list)
+		}
+		c.expireJudges(now)
+		if _, ok := c.Get("judge"); ok == tt.expired {
+			t.Errorf("%s: kept %v, want %v", tt.name, ok, !tt.expired)
+		}
+		c.Close()
+	}
+}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn"
+	"github.com/sirupsen/logrus"
+)
+
+// Handler serves the aggregated state:
+//
+//	GET  /judges             state of all judges
+//	GET  /judges/{uid}        state of one judge
+//	GET  /judges/{uid}/result the result a judge reported
+//	POST /report             receiver for hostconn webhooks
+func (c *Collector) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/judges", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, c.List())
+	})
+	mux.HandleFunc("/judges/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Split(strings.TrimPrefix(r.URL.Path, "/judges/"), "/")
+		if len(path) > 2 || (len(path) == 2 && path[1] != "result") {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		j, ok := c.Get(path[0])
+		if !ok {
+			writeError(w, http.StatusNotFound, "no such judge")
+			return
+		}
+		if len(path) == 1 {
+			writeJSON(w, http.StatusOK, j)
+		} else if j.Result == nil {
+			writeError(w, http.StatusConflict, "no result yet")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(j.Result)
+		}
+	})
+	mux.HandleFunc("/report", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, hostconn.MaxWebhookSize))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if ack, err := c.Handle(data); ack == nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Warnf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"net"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn"
+	"github.com/sirupsen/logrus"
+)
+
+// ListenUDP receives datagrams on addr and acks them to their source. The
+// returned address is useful when addr has port 0.
+func (c *Collector) ListenUDP(addr string) (net.Addr, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c.addCloser(conn.Close)
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			ack, err := c.Handle(buf[:n])
+			if ack == nil {
+				logrus.Warnf("Dropping datagram from %v: %v", from, err)
+				continue
+			}
+			conn.WriteTo(marshalAck(ack), from)
+		}
+	}()
+	return conn.LocalAddr(), nil
+}
+
+// ListenStream accepts tcp or unix connections carrying frames written by
+// hostconn.WriteFrame, and acks every message on the same connection.
+func (c *Collector) ListenStream(network, addr string) (net.Addr, error) {
+	lis, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	c.addCloser(lis.Close)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go c.serveStream(conn)
+		}
+	}()
+	return lis.Addr(), nil
+}
+
+func (c *Collector) serveStream(conn net.Conn) {
+	defer conn.Close()
+	for {
+		data, err := hostconn.ReadFrame(conn)
+		if err != nil {
+			return
+		}
+		ack, err := c.Handle(data)
+		if ack == nil {
+			logrus.Warnf("Closing connection from %v: %v", conn.RemoteAddr(), err)
+			return
+		}
+		if err := hostconn.WriteFrame(conn, marshalAck(ack)); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn"
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn/message"
+)
+
+var (
+	ErrBadSignature = errors.New("bad signature")
+	ErrOutdated     = errors.New("timestamp out of the accepted window")
+	ErrReplay       = errors.New("nonce was already used")
+)
+
+// Verifier rejects messages which were not signed with the shared key, whose
+// timestamp is more than MaxSkew away from now, or whose nonce was seen before.
+type Verifier struct {
+	Key     []byte
+	MaxSkew time.Duration
+
+	mut    sync.Mutex
+	nonces map[string]time.Time
+	pruned time.Time
+	now    func() time.Time
+}
+
+func NewVerifier(key []byte, maxSkew time.Duration) *Verifier {
+	if maxSkew <= 0 {
+		maxSkew = 5 * time.Minute
+	}
+	return &Verifier{
+		Key:     key,
+		MaxSkew: maxSkew,
+		nonces:  make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Verify checks msg. ErrReplay is only returned for messages with a valid
+// signature, they are retransmissions or replays of an authentic message.
+func (v *Verifier) Verify(msg *message.StateMessage) error {
+	if len(msg.Signature) == 0 || !hostconn.CheckSignature(msg, v.Key) {
+		return ErrBadSignature
+	}
+	now := v.now()
+	sent := time.Unix(0, msg.Timestamp)
+	if sent.Before(now.Add(-v.MaxSkew)) || sent.After(now.Add(v.MaxSkew)) {
+		return ErrOutdated
+	}
+
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	if now.Sub(v.pruned) > v.MaxSkew {
+		// nonces older than the window can not pass the timestamp check
+		for nonce, t := range v.nonces {
+			if now.Sub(t) > 2*v.MaxSkew {
+				delete(v.nonces, nonce)
+			}
+		}
+		v.pruned = now
+	}
+	nonce := msg.Uid + "\x00" + string(msg.Nonce)
+	if _, ok := v.nonces[nonce]; ok {
+		return ErrReplay
+	}
+	v.nonces[nonce] = now
+	return nil
+}
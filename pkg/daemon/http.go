@@ -0,0 +1,125 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// maxSubmissionSize limits the request body of POST /submissions.
+const maxSubmissionSize = 4 << 20
+
+// Handler serves the judge HTTP API:
+//
+//	POST /submissions             queue a submission, returns its record
+//	GET  /submissions/{id}        status and, when finished, the result
+//	GET  /submissions/{id}/result the result only
+//	GET  /healthz                 queue statistics
+//	GET  /metrics                 judge metrics in the Prometheus text format
+func Handler(p *Pool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/submissions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		sub := &Submission{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubmissionSize)).Decode(sub); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rec, err := p.Submit(sub)
+		switch err {
+		case nil:
+			writeJSON(w, http.StatusAccepted, rec)
+		case ErrQueueFull, ErrDraining:
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+		default:
+			writeError(w, http.StatusBadRequest, err.Error())
+		}
+	})
+	mux.HandleFunc("/submissions/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		path := strings.Split(strings.TrimPrefix(r.URL.Path, "/submissions/"), "/")
+		if len(path) > 2 || (len(path) == 2 && path[1] != "result") {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		rec, ok := p.Get(path[0])
+		if !ok {
+			writeError(w, http.StatusNotFound, ErrNotFound.Error())
+			return
+		}
+		if len(path) == 1 {
+			writeJSON(w, http.StatusOK, rec)
+		} else {
+			writeResult(w, rec, false)
+		}
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, p.Stats())
+	})
+	mux.Handle("/metrics", metrics.Default.Handler())
+	return mux
+}
+
+// AdminHandler serves what the feedback policy hides from contestants, it must
+// only be reachable by admins, on a listener of its own:
+//
+//	GET /submissions/{id}/result the result with nothing hidden
+func AdminHandler(p *Pool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/submissions/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		path := strings.Split(strings.TrimPrefix(r.URL.Path, "/submissions/"), "/")
+		if len(path) != 2 || path[1] != "result" {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		rec, ok := p.Get(path[0])
+		if !ok {
+			writeError(w, http.StatusNotFound, ErrNotFound.Error())
+			return
+		}
+		writeResult(w, rec, true)
+	})
+	return mux
+}
+
+// writeResult writes the result of rec, unredacted for admins, or why there is
+// none.
+func writeResult(w http.ResponseWriter, rec *Record, unredacted bool) {
+	switch rec.Status {
+	case StatusFinished:
+		if unredacted {
+			writeJSON(w, http.StatusOK, rec.Result.Unredacted())
+		} else {
+			writeJSON(w, http.StatusOK, rec.Result)
+		}
+	case StatusFailed:
+		writeError(w, http.StatusInternalServerError, rec.Error)
+	default:
+		writeError(w, http.StatusConflict, "submission is "+string(rec.Status))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Warnf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/pci15"
+)
+
+func TestAdminResult(t *testing.T) {
+	p := NewPool(&Config{})
+	p.records = map[string]*Record{
+		"done":    {ID: "done", Status: StatusFinished, Result: &pci15.JudgeResult{Verdict: "AC"}},
+		"running": {ID: "running", Status: StatusRunning},
+	}
+	tests := []struct {
+		handler http.Handler
+		path    string
+		code    int
+	}{
+		{Handler(p), "/submissions/done/result", http.StatusOK},
+		{Handler(p), "/submissions/done/admin", http.StatusNotFound},
+		{AdminHandler(p), "/submissions/done/result", http.StatusOK},
+		{AdminHandler(p), "/submissions/running/result", http.StatusConflict},
+		{AdminHandler(p), "/submissions/missing/result", http.StatusNotFound},
+		{AdminHandler(p), "/submissions/done", http.StatusNotFound},
+		{AdminHandler(p), "/healthz", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: got %d, want %d", tt.path, w.Code, tt.code)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/pci15"
+	"github.com/erjiaqing/PCIJudger2/pkg/trace"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrQueueFull = errors.New("submission queue is full")
+	ErrDraining  = errors.New("judge daemon is shutting down")
+	ErrNotFound  = errors.New("no such submission")
+)
+
+type Config struct {
+	Judge      *pci15.Config
+	Workers    int
+	QueueSize  int
+	ProblemGit string // URL of problem repositories, %s is replaced by problem id
+	// ProblemVersions are the checkouts kept of every problem, 3 if 0
+	ProblemVersions int
+	// finished records are forgotten after RecordTTL, and the oldest ones
+	// once there are more than MaxRecords; 1 hour and 10000 if 0
+	RecordTTL  time.Duration
+	MaxRecords int
+}
+
+// Pool queues submissions and judges them on a bounded number of workers.
+type Pool struct {
+	conf     *Config
+	queue    chan *Record
+	problems *problemCache
+
+	mut      sync.RWMutex
+	records  map[string]*Record
+	draining bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewPool(conf *Config) *Pool {
+	if conf.Workers <= 0 {
+		conf.Workers = 1
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = 64
+	}
+	if conf.ProblemVersions <= 0 {
+		conf.ProblemVersions = 3
+	}
+	if conf.RecordTTL <= 0 {
+		conf.RecordTTL = time.Hour
+	}
+	if conf.MaxRecords <= 0 {
+		conf.MaxRecords = 10000
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Pool{
+		conf:     conf,
+		queue:    make(chan *Record, conf.QueueSize),
+		problems: newProblemCache(conf),
+		records:  make(map[string]*Record),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start launches the workers, they run until Shutdown.
+func (p *Pool) Start() {
+	for i := 0; i < p.conf.Workers; i++ {
+		p.wg.Add(1)
+		go func(worker int) {
+			defer p.wg.Done()
+			for rec := range p.queue {
+				p.run(worker, rec)
+			}
+		}(i)
+	}
+}
+
+func (p *Pool) Submit(sub *Submission) (*Record, error) {
+	if sub.Language == "" || sub.Problem == "" || sub.Version == "" {
+		return nil, errors.New("lang, problem and version are required")
+	}
+	rec := &Record{
+		ID:         pci15.GetRandomString(),
+		Submission: sub,
+		Status:     StatusQueued,
+		Created:    time.Now(),
+		notify:     make(chan struct{}),
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if p.draining {
+		return nil, ErrDraining
+	}
+	select {
+	case p.queue <- rec:
+	default:
+		return nil, ErrQueueFull
+	}
+	p.evict(rec.Created)
+	p.records[rec.ID] = rec
+	return rec.snapshot(), nil
+}
+
+// evict forgets the finished records older than the TTL, then the oldest
+// finished ones while there are too many records. p.mut must be held.
+func (p *Pool) evict(now time.Time) {
+	var finished []*Record
+	for id, rec := range p.records {
+		if !rec.done() {
+			continue
+		}
+		if now.Sub(*rec.Finished) > p.conf.RecordTTL {
+			delete(p.records, id)
+			continue
+		}
+		finished = append(finished, rec)
+	}
+	extra := len(p.records) + 1 - p.conf.MaxRecords
+	if extra <= 0 {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].Finished.Before(*finished[j].Finished)
+	})
+	for i := 0; i < extra && i < len(finished); i++ {
+		delete(p.records, finished[i].ID)
+	}
+}
+
+// Get returns a copy of the record of submission id.
+func (p *Pool) Get(id string) (*Record, bool) {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	rec, ok := p.records[id]
+	if !ok {
+		return nil, false
+	}
+	return rec.snapshot(), true
+}
+
+// Watch returns a channel of all events of submission id, from the first one
+// to judge_finished. The channel is closed after the last event or when ctx
+// is cancelled.
+func (p *Pool) Watch(ctx context.Context, id string) (<-chan *pci15.JudgeEvent, error) {
+	p.mut.RLock()
+	rec, ok := p.records[id]
+	p.mut.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	ch := make(chan *pci15.JudgeEvent)
+	go func() {
+		defer close(ch)
+		next := 0
+		for {
+			p.mut.RLock()
+			events := rec.events[next:]
+			notify := rec.notify
+			done := rec.done()
+			p.mut.RUnlock()
+			for _, ev := range events {
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+			next += len(events)
+			if done {
+				return
+			}
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (p *Pool) Stats() *Stats {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	stats := &Stats{
+		Workers:  p.conf.Workers,
+		Draining: p.draining,
+	}
+	for _, rec := range p.records {
+		switch rec.Status {
+		case StatusQueued:
+			stats.Queued++
+		case StatusRunning:
+			stats.Running++
+		default:
+			stats.Finished++
+		}
+	}
+	return stats
+}
+
+// Shutdown stops accepting submissions and waits for queued and running ones
+// to be judged. If ctx expires first, running judgements are cancelled.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mut.Lock()
+	if !p.draining {
+		p.draining = true
+		close(p.queue)
+	}
+	p.mut.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		logrus.Warnf("Drain timed out, cancelling running judgements")
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) update(rec *Record, f func(rec *Record)) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	f(rec)
+}
+
+func (p *Pool) run(worker int, rec *Record) {
+	logrus.Infof("[worker %d] Judging submission %s", worker, rec.ID)
+	p.update(rec, func(rec *Record) {
+		rec.Status = StatusRunning
+		now := time.Now()
+		rec.Started = &now
+	})
+
+	result, err := p.judge(rec)
+
+	p.update(rec, func(rec *Record) {
+		now := time.Now()
+		rec.Finished = &now
+		finished := &pci15.JudgeEvent{
+			Type:   pci15.EventJudgeFinished,
+			Time:   now,
+			Result: result,
+		}
+		if err != nil {
+			rec.Status = StatusFailed
+			rec.Error = err.Error()
+			finished.Error = err.Error()
+		} else {
+			rec.Status = StatusFinished
+			rec.Result = result
+		}
+		rec.appendEvent(finished)
+	})
+	if err != nil {
+		logrus.Errorf("[worker %d] Submission %s failed: %v", worker, rec.ID, err)
+	} else {
+		logrus.Infof("[worker %d] Submission %s: %s", worker, rec.ID, result.Verdict)
+	}
+}
+
+func (p *Pool) judge(rec *Record) (*pci15.JudgeResult, error) {
+	sub := rec.Submission
+	ctx := p.ctx
+	if p.conf.Judge.Trace {
+		// started here so that fetching the problem is part of the trace
+		ctx = trace.NewContext(ctx, trace.New())
+	}
+	sink := &progressSink{pool: p, rec: rec}
+	sink.Emit(&pci15.JudgeEvent{Type: pci15.EventFetchStarted, Time: time.Now()})
+	problemDir, release, err := p.problems.acquire(ctx, sub.Problem, sub.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare problem: %v", err)
+	}
+	defer release()
+
+	tmpDir := filepath.Join(p.conf.Judge.Tmp, "submission-"+rec.ID)
+	if err := os.MkdirAll(tmpDir, 0777); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	sourceFile := filepath.Join(tmpDir, "source")
+	if err := ioutil.WriteFile(sourceFile, []byte(sub.Source), 0644); err != nil {
+		return nil, err
+	}
+
+	judgeConf := *p.conf.Judge
+	judgeConf.Tmp = tmpDir
+	judgeConf.IsDocker = false
+	judgeConf.Problem = problemDir
+	judgeConf.HostSocket = nil
+	judgeConf.Events = sink
+
+	code := &pci15.SourceCode{
+		Source:   sourceFile,
+		Language: sub.Language,
+	}
+	return pci15.Judge(ctx, &judgeConf, code, problemDir)
+}
+
+type progressSink struct {
+	pool *Pool
+	rec  *Record
+}
+
+// Emit records every event but judge_finished, which Pool.run appends once the
+// record is updated, so that it is also sent when Judge was never called.
+func (s *progressSink) Emit(ev *pci15.JudgeEvent) {
+	if ev.Type == pci15.EventJudgeFinished {
+		return
+	}
+	s.pool.update(s.rec, func(rec *Record) {
+		if ev.Type == pci15.EventTestFinished {
+			rec.Judged = ev.Judged
+			rec.Total = ev.Total
+		}
+		rec.appendEvent(ev)
+	})
+}
@@ -0,0 +1,33 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvict(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+	p := NewPool(&Config{RecordTTL: time.Hour, MaxRecords: 4})
+	p.records = map[string]*Record{
+		"expired": {ID: "expired", Status: StatusFinished, Finished: at(2 * time.Hour)},
+		"old":     {ID: "old", Status: StatusFailed, Finished: at(30 * time.Minute)},
+		"new":     {ID: "new", Status: StatusFinished, Finished: at(time.Minute)},
+		"running": {ID: "running", Status: StatusRunning},
+		"queued":  {ID: "queued", Status: StatusQueued},
+	}
+	p.evict(now)
+	for _, id := range []string{"expired", "old"} {
+		if _, ok := p.records[id]; ok {
+			t.Errorf("%s was kept", id)
+		}
+	}
+	for _, id := range []string{"new", "running", "queued"} {
+		if _, ok := p.records[id]; !ok {
+			t.Errorf("%s was evicted", id)
+		}
+	}
+}
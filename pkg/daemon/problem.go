@@ -0,0 +1,157 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/pci15"
+	"github.com/sirupsen/logrus"
+)
+
+// problemCache keeps built problem checkouts in conf.Judge.ProblemPath, one
+// per problem version, so that the checkout and the compiled checker are
+// shared by all submissions of the same problem version. The least recently
+// used versions of a problem not in use are removed once there are more than
+// conf.ProblemVersions.
+type problemCache struct {
+	conf    *Config
+	mut     sync.Mutex
+	entries map[problemKey]*problemEntry
+}
+
+type problemKey struct {
+	problem string
+	version string
+}
+
+// dir is the name of the checkout in conf.Judge.ProblemPath.
+func (k problemKey) dir() string {
+	return k.problem + "@" + k.version
+}
+
+type problemEntry struct {
+	l    sync.RWMutex
+	refs int // acquire calls holding or waiting for l, under problemCache.mut
+	used time.Time
+}
+
+func newProblemCache(conf *Config) *problemCache {
+	return &problemCache{
+		conf:    conf,
+		entries: make(map[problemKey]*problemEntry),
+	}
+}
+
+// ref returns the entry of k, which is not evicted until unref is called.
+func (c *problemCache) ref(k problemKey) *problemEntry {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	e, ok := c.entries[k]
+	if !ok {
+		e = &problemEntry{}
+		c.entries[k] = e
+	}
+	e.refs++
+	e.used = time.Now()
+	return e
+}
+
+func (c *problemCache) unref(e *problemEntry) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	e.refs--
+}
+
+// evict removes the least recently used checkouts of the problem of k not in
+// use, keeping conf.ProblemVersions of them.
+func (c *problemCache) evict(k problemKey) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	var keys []problemKey
+	for other := range c.entries {
+		if other.problem == k.problem {
+			keys = append(keys, other)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].used.After(c.entries[keys[j]].used)
+	})
+	for i, other := range keys {
+		if i < c.conf.ProblemVersions || c.entries[other].refs > 0 {
+			continue
+		}
+		// nobody holds or waits for the entry, and new acquirers wait
+		// for c.mut then fetch the problem again
+		delete(c.entries, other)
+		logrus.Infof("Removing problem %s at %s", other.problem, other.version)
+		if err := os.RemoveAll(filepath.Join(c.conf.Judge.ProblemPath, other.dir())); err != nil {
+			logrus.Warnf("Failed to remove problem %s at %s: %v", other.problem, other.version, err)
+		}
+	}
+}
+
+// acquire makes sure problem is checked out at version and returns its
+// directory, which stays valid until release is called.
+func (c *problemCache) acquire(ctx context.Context, problem, version string) (string, func(), error) {
+	if problem != filepath.Base(problem) || problem == "." || problem == ".." || strings.Contains(problem, "@") {
+		return "", nil, fmt.Errorf("invalid problem id ``%s''", problem)
+	}
+	if version != filepath.Base(version) || version == "." || version == ".." {
+		return "", nil, fmt.Errorf("invalid problem version ``%s''", version)
+	}
+	k := problemKey{problem, version}
+	problemDir := filepath.Join(c.conf.Judge.ProblemPath, k.dir())
+	e := c.ref(k)
+	for {
+		e.l.RLock()
+		if pci15.CheckProblem(c.conf.Judge, k.dir(), version) {
+			return problemDir, func() {
+				e.l.RUnlock()
+				c.unref(e)
+			}, nil
+		}
+		e.l.RUnlock()
+
+		e.l.Lock()
+		if !pci15.CheckProblem(c.conf.Judge, k.dir(), version) {
+			if err := c.fetch(ctx, k); err != nil {
+				e.l.Unlock()
+				c.unref(e)
+				return "", nil, err
+			}
+			if !pci15.CheckProblem(c.conf.Judge, k.dir(), version) {
+				e.l.Unlock()
+				c.unref(e)
+				return "", nil, fmt.Errorf("problem ``%s'' is not at %s after fetching, use the full commit hash", problem, version)
+			}
+			c.evict(k)
+		}
+		e.l.Unlock()
+	}
+}
+
+func (c *problemCache) fetch(ctx context.Context, k problemKey) error {
+	if c.conf.ProblemGit == "" {
+		return fmt.Errorf("problem ``%s'' at %s is not available and no git url is configured", k.problem, k.version)
+	}
+	logrus.Infof("Fetching problem %s at %s", k.problem, k.version)
+	problemDir := filepath.Join(c.conf.Judge.ProblemPath, k.dir())
+	if err := os.RemoveAll(problemDir); err != nil {
+		return err
+	}
+	if err := pci15.GetProblem(ctx, c.conf.Judge, k.dir(), fmt.Sprintf(c.conf.ProblemGit, k.problem), k.version); err != nil {
+		// the checkout may be at version without having been built, it
+		// must not be found by CheckProblem
+		if err := os.RemoveAll(problemDir); err != nil {
+			logrus.Warnf("Failed to remove problem %s at %s: %v", k.problem, k.version, err)
+		}
+		return err
+	}
+	return nil
+}
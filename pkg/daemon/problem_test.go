@@ -0,0 +1,57 @@
+package daemon
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/pci15"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestAcquireBrokenProblem(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// a problem repository without problem.yaml, which fails to build
+	repoDir := filepath.Join(tmp, "repo", "broken")
+	repo, err := git.PlainInit(repoDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(repoDir, "README"), []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Add("README"); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := tree.Commit("broken", &git.CommitOptions{Author: &object.Signature{Name: "test", When: time.Now()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	judge := &pci15.Config{ProblemPath: filepath.Join(tmp, "problem"), Tmp: filepath.Join(tmp, "tmp")}
+	c := newProblemCache(&Config{Judge: judge, ProblemGit: filepath.Join(tmp, "repo", "%s"), ProblemVersions: 3})
+	// the checkout of a problem failing to build is never used, also when
+	// acquired again
+	for i := 0; i < 2; i++ {
+		if _, release, err := c.acquire(context.Background(), "broken", hash.String()); err == nil {
+			release()
+			t.Fatalf("acquire %d: broken problem was acquired", i+1)
+		}
+		if pci15.CheckProblem(judge, "broken@"+hash.String(), hash.String()) {
+			t.Errorf("acquire %d: checkout of the broken problem was kept", i+1)
+		}
+	}
+}
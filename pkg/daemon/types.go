@@ -0,0 +1,63 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/pci15"
+)
+
+type Status string
+
+const (
+	StatusQueued   Status = "queued"
+	StatusRunning  Status = "running"
+	StatusFinished Status = "finished"
+	StatusFailed   Status = "failed"
+)
+
+type Submission struct {
+	Source   string `json:"source"`
+	Language string `json:"lang"`
+	Problem  string `json:"problem"`
+	Version  string `json:"version"`
+}
+
+type Record struct {
+	ID         string             `json:"id"`
+	Submission *Submission        `json:"-"`
+	Status     Status             `json:"status"`
+	Judged     int                `json:"judged"`
+	Total      int                `json:"total"`
+	Error      string             `json:"error,omitempty"`
+	Result     *pci15.JudgeResult `json:"result,omitempty"`
+	Created    time.Time          `json:"created"`
+	Started    *time.Time         `json:"started,omitempty"`
+	Finished   *time.Time         `json:"finished,omitempty"`
+
+	events []*pci15.JudgeEvent
+	notify chan struct{}
+}
+
+func (r *Record) done() bool {
+	return r.Status == StatusFinished || r.Status == StatusFailed
+}
+
+// appendEvent records ev and wakes up everyone watching the record.
+func (r *Record) appendEvent(ev *pci15.JudgeEvent) {
+	r.events = append(r.events, ev)
+	close(r.notify)
+	r.notify = make(chan struct{})
+}
+
+func (r *Record) snapshot() *Record {
+	ret := *r
+	return &ret
+}
+
+type Stats struct {
+	Workers  int  `json:"workers"`
+	Queued   int  `json:"queued"`
+	Running  int  `json:"running"`
+	Finished int  `json:"finished"`
+	Draining bool `json:"draining"`
+}
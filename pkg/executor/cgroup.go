@@ -10,6 +10,7 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/erjiaqing/PCIJudger2/pkg/metrics"
 	"github.com/erjiaqing/PCIJudger2/pkg/util"
 	"github.com/sirupsen/logrus"
 )
@@ -111,6 +112,14 @@ func (c *CGroup) AddProcess(pid int) error {
 
 // CleanUp cleans up the cgroup created
 func (c *CGroup) CleanUp() error {
+	err := c.cleanUp()
+	if err != nil {
+		metrics.CGroupCleanupFailures.Inc()
+	}
+	return err
+}
+
+func (c *CGroup) cleanUp() error {
 	c.Mutex.Lock()
 	defer c.Mutex.Unlock()
 	// kill all processes
@@ -20,10 +20,102 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type MessageType int32
+
+const (
+	MessageType_STATE     MessageType = 0
+	MessageType_HEARTBEAT MessageType = 1
+	MessageType_RESULT    MessageType = 2
+)
+
+var MessageType_name = map[int32]string{
+	0: "STATE",
+	1: "HEARTBEAT",
+	2: "RESULT",
+}
+
+var MessageType_value = map[string]int32{
+	"STATE":     0,
+	"HEARTBEAT": 1,
+	"RESULT":    2,
+}
+
+func (x MessageType) String() string {
+	return proto.EnumName(MessageType_name, int32(x))
+}
+
+func (MessageType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_85e40b83b4d50a8d, []int{0}
+}
+
+// Phase of a judgement, a judge goes through them in this order, except that
+// RUNNING and CHECKING alternate and FAILED may follow any other phase.
+type Phase int32
+
+const (
+	Phase_UNKNOWN          Phase = 0
+	Phase_FETCHING_PROBLEM Phase = 1
+	Phase_PREPARING        Phase = 2
+	Phase_COMPILING        Phase = 3
+	Phase_RUNNING          Phase = 4
+	Phase_CHECKING         Phase = 5
+	Phase_FINISHED         Phase = 6
+	Phase_FAILED           Phase = 7
+)
+
+var Phase_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "FETCHING_PROBLEM",
+	2: "PREPARING",
+	3: "COMPILING",
+	4: "RUNNING",
+	5: "CHECKING",
+	6: "FINISHED",
+	7: "FAILED",
+}
+
+var Phase_value = map[string]int32{
+	"UNKNOWN":          0,
+	"FETCHING_PROBLEM": 1,
+	"PREPARING":        2,
+	"COMPILING":        3,
+	"RUNNING":          4,
+	"CHECKING":         5,
+	"FINISHED":         6,
+	"FAILED":           7,
+}
+
+func (x Phase) String() string {
+	return proto.EnumName(Phase_name, int32(x))
+}
+
+func (Phase) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_85e40b83b4d50a8d, []int{1}
+}
+
 type StateMessage struct {
-	State                string   `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
-	Uid                  string   `protobuf:"bytes,2,opt,name=uid,proto3" json:"uid,omitempty"`
-	Progress             int32    `protobuf:"varint,3,opt,name=progress,proto3" json:"progress,omitempty"`
+	// state is the legacy status code derived from phase: "00" preparing,
+	// "01" compiling, "02" compiled, "10" testing and "FF" done
+	State    string `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	Uid      string `protobuf:"bytes,2,opt,name=uid,proto3" json:"uid,omitempty"`
+	Progress int32  `protobuf:"varint,3,opt,name=progress,proto3" json:"progress,omitempty"`
+	// seq increases with every message sent by the same uid, it may skip
+	// numbers when state messages are coalesced
+	Seq  uint64      `protobuf:"varint,4,opt,name=seq,proto3" json:"seq,omitempty"`
+	Type MessageType `protobuf:"varint,5,opt,name=type,proto3,enum=message.MessageType" json:"type,omitempty"`
+	// result is the json judge result, only set in RESULT messages
+	Result []byte `protobuf:"bytes,6,opt,name=result,proto3" json:"result,omitempty"`
+	Phase  Phase  `protobuf:"varint,7,opt,name=phase,proto3,enum=message.Phase" json:"phase,omitempty"`
+	// test is the 1-based number of the test case the phase refers to
+	Test  int32 `protobuf:"varint,8,opt,name=test,proto3" json:"test,omitempty"`
+	Total int32 `protobuf:"varint,9,opt,name=total,proto3" json:"total,omitempty"`
+	// verdict is the first non-AC verdict so far, or the final verdict
+	Verdict string `protobuf:"bytes,10,opt,name=verdict,proto3" json:"verdict,omitempty"`
+	// timestamp is the unix time in nanoseconds the message was signed at
+	Timestamp int64  `protobuf:"varint,11,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Nonce     []byte `protobuf:"bytes,12,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	// signature is the HMAC-SHA256 of the fields, see hostconn.Sign
+	Signature            []byte   `protobuf:"bytes,13,opt,name=signature,proto3" json:"signature,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -75,19 +167,169 @@ func (m *StateMessage) GetProgress() int32 {
 	return 0
 }
 
+func (m *StateMessage) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *StateMessage) GetType() MessageType {
+	if m != nil {
+		return m.Type
+	}
+	return MessageType_STATE
+}
+
+func (m *StateMessage) GetResult() []byte {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (m *StateMessage) GetPhase() Phase {
+	if m != nil {
+		return m.Phase
+	}
+	return Phase_UNKNOWN
+}
+
+func (m *StateMessage) GetTest() int32 {
+	if m != nil {
+		return m.Test
+	}
+	return 0
+}
+
+func (m *StateMessage) GetTotal() int32 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+func (m *StateMessage) GetVerdict() string {
+	if m != nil {
+		return m.Verdict
+	}
+	return ""
+}
+
+func (m *StateMessage) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *StateMessage) GetNonce() []byte {
+	if m != nil {
+		return m.Nonce
+	}
+	return nil
+}
+
+func (m *StateMessage) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// Ack is sent back by the host for every StateMessage it received
+type Ack struct {
+	Uid string `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Seq uint64 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	// signature is the HMAC-SHA256 of uid and seq, see hostconn.SignAck
+	Signature            []byte   `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Ack) Reset()         { *m = Ack{} }
+func (m *Ack) String() string { return proto.CompactTextString(m) }
+func (*Ack) ProtoMessage()    {}
+func (*Ack) Descriptor() ([]byte, []int) {
+	return fileDescriptor_85e40b83b4d50a8d, []int{1}
+}
+
+func (m *Ack) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Ack.Unmarshal(m, b)
+}
+func (m *Ack) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Ack.Marshal(b, m, deterministic)
+}
+func (m *Ack) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Ack.Merge(m, src)
+}
+func (m *Ack) XXX_Size() int {
+	return xxx_messageInfo_Ack.Size(m)
+}
+func (m *Ack) XXX_DiscardUnknown() {
+	xxx_messageInfo_Ack.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Ack proto.InternalMessageInfo
+
+func (m *Ack) GetUid() string {
+	if m != nil {
+		return m.Uid
+	}
+	return ""
+}
+
+func (m *Ack) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *Ack) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
 func init() {
+	proto.RegisterEnum("message.MessageType", MessageType_name, MessageType_value)
+	proto.RegisterEnum("message.Phase", Phase_name, Phase_value)
 	proto.RegisterType((*StateMessage)(nil), "message.StateMessage")
+	proto.RegisterType((*Ack)(nil), "message.Ack")
 }
 
 func init() { proto.RegisterFile("host.proto", fileDescriptor_85e40b83b4d50a8d) }
 
 var fileDescriptor_85e40b83b4d50a8d = []byte{
-	// 111 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0xca, 0xc8, 0x2f, 0x2e,
-	0xd1, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0xcf, 0x4d, 0x2d, 0x2e, 0x4e, 0x4c, 0x4f, 0x55,
-	0x0a, 0xe2, 0xe2, 0x09, 0x2e, 0x49, 0x2c, 0x49, 0xf5, 0x85, 0xf0, 0x85, 0x44, 0xb8, 0x58, 0x8b,
-	0x41, 0x7c, 0x09, 0x46, 0x05, 0x46, 0x0d, 0xce, 0x20, 0x08, 0x47, 0x48, 0x80, 0x8b, 0xb9, 0x34,
-	0x33, 0x45, 0x82, 0x09, 0x2c, 0x06, 0x62, 0x0a, 0x49, 0x71, 0x71, 0x14, 0x14, 0xe5, 0xa7, 0x17,
-	0xa5, 0x16, 0x17, 0x4b, 0x30, 0x2b, 0x30, 0x6a, 0xb0, 0x06, 0xc1, 0xf9, 0x49, 0x6c, 0x60, 0x3b,
-	0x8c, 0x01, 0x01, 0x00, 0x00, 0xff, 0xff, 0x33, 0x50, 0x01, 0xb9, 0x71, 0x00, 0x00, 0x00,
+	// 421 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x92, 0xcf, 0x6e, 0xda, 0x40,
+	0x10, 0xc6, 0xb3, 0xfe, 0x8b, 0x07, 0x12, 0xad, 0x46, 0xa8, 0x5a, 0x55, 0x3d, 0x58, 0x51, 0x0f,
+	0x56, 0x0e, 0x1c, 0x9a, 0x27, 0x70, 0xc8, 0x02, 0x56, 0xc0, 0x58, 0x8b, 0x51, 0x8f, 0x95, 0x4b,
+	0x56, 0x04, 0x35, 0x60, 0xd7, 0xbb, 0x54, 0xca, 0xa1, 0x2f, 0xdb, 0x27, 0xa9, 0x76, 0x4d, 0x42,
+	0x9b, 0xdb, 0xfc, 0xbe, 0x1d, 0x7f, 0x33, 0xf3, 0xc9, 0x00, 0x4f, 0xb5, 0xd2, 0xa3, 0xa6, 0xad,
+	0x75, 0x8d, 0xe1, 0x5e, 0x2a, 0x55, 0x6d, 0xe5, 0xf5, 0x1f, 0x07, 0x06, 0x2b, 0x5d, 0x69, 0xb9,
+	0xe8, 0x04, 0x1c, 0x82, 0xaf, 0x0c, 0x33, 0x12, 0x93, 0x24, 0x12, 0x1d, 0x20, 0x05, 0xf7, 0xb8,
+	0x7b, 0x64, 0x8e, 0xd5, 0x4c, 0x89, 0x1f, 0xa1, 0xd7, 0xb4, 0xf5, 0xb6, 0x95, 0x4a, 0x31, 0x37,
+	0x26, 0x89, 0x2f, 0xde, 0xd8, 0x74, 0x2b, 0xf9, 0x93, 0x79, 0x31, 0x49, 0x3c, 0x61, 0x4a, 0x4c,
+	0xc0, 0xd3, 0x2f, 0x8d, 0x64, 0x7e, 0x4c, 0x92, 0xab, 0x2f, 0xc3, 0xd1, 0x69, 0xfc, 0xe8, 0x34,
+	0xb5, 0x7c, 0x69, 0xa4, 0xb0, 0x1d, 0xf8, 0x01, 0x82, 0x56, 0xaa, 0xe3, 0xb3, 0x66, 0x41, 0x4c,
+	0x92, 0x81, 0x38, 0x11, 0x7e, 0x06, 0xbf, 0x79, 0xaa, 0x94, 0x64, 0xa1, 0xb5, 0xb8, 0x7a, 0xb3,
+	0x28, 0x8c, 0x2a, 0xba, 0x47, 0x44, 0xf0, 0xb4, 0x54, 0x9a, 0xf5, 0xec, 0x46, 0xb6, 0x36, 0x17,
+	0xe9, 0x5a, 0x57, 0xcf, 0x2c, 0xb2, 0x62, 0x07, 0xc8, 0x20, 0xfc, 0x25, 0xdb, 0xc7, 0xdd, 0x46,
+	0x33, 0xb0, 0x57, 0xbd, 0x22, 0x7e, 0x82, 0x48, 0xef, 0xf6, 0x52, 0xe9, 0x6a, 0xdf, 0xb0, 0x7e,
+	0x4c, 0x12, 0x57, 0x9c, 0x05, 0xe3, 0x76, 0xa8, 0x0f, 0x1b, 0xc9, 0x06, 0x76, 0xbd, 0x0e, 0xcc,
+	0x37, 0x6a, 0xb7, 0x3d, 0x54, 0xfa, 0xd8, 0x4a, 0x76, 0x69, 0x5f, 0xce, 0xc2, 0xf5, 0x14, 0xdc,
+	0x74, 0xf3, 0xe3, 0x35, 0x44, 0x72, 0x0e, 0xf1, 0x14, 0x94, 0x73, 0x0e, 0xea, 0x3f, 0x23, 0xf7,
+	0x9d, 0xd1, 0xcd, 0x2d, 0xf4, 0xff, 0x49, 0x0c, 0x23, 0xf0, 0x57, 0x65, 0x5a, 0x72, 0x7a, 0x81,
+	0x97, 0x10, 0xcd, 0x78, 0x2a, 0xca, 0x3b, 0x9e, 0x96, 0x94, 0x20, 0x40, 0x20, 0xf8, 0x6a, 0x3d,
+	0x2f, 0xa9, 0x73, 0xf3, 0x1b, 0x7c, 0x9b, 0x11, 0xf6, 0x21, 0x5c, 0xe7, 0x0f, 0xf9, 0xf2, 0x6b,
+	0x4e, 0x2f, 0x70, 0x08, 0x74, 0xc2, 0xcb, 0xf1, 0x2c, 0xcb, 0xa7, 0xdf, 0x0a, 0xb1, 0xbc, 0x9b,
+	0xf3, 0x05, 0x25, 0xc6, 0xa6, 0x10, 0xbc, 0x48, 0x45, 0x96, 0x4f, 0xa9, 0x63, 0x70, 0xbc, 0x5c,
+	0x14, 0xd9, 0xdc, 0xa0, 0x6b, 0x0c, 0xc4, 0x3a, 0xcf, 0x0d, 0x78, 0x38, 0x80, 0xde, 0x78, 0xc6,
+	0xc7, 0x0f, 0x86, 0x7c, 0x43, 0x93, 0x2c, 0xcf, 0x56, 0x33, 0x7e, 0x4f, 0x03, 0x33, 0x7e, 0x92,
+	0x66, 0x73, 0x7e, 0x4f, 0xc3, 0xef, 0x81, 0xfd, 0xe3, 0x6e, 0xff, 0x0e, 0x00, 0x4f, 0xd6, 0x72,
+	0xfe, 0x7f, 0x02, 0x00, 0x00,
 }
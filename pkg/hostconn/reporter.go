@@ -0,0 +1,327 @@
+/* hostconn/reporter.go
+ * Deliver judge state and result to the host, in order, with retries
+ */
+
+package hostconn
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn/message"
+	"github.com/erjiaqing/PCIJudger2/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+// Largest encoded messages carried by the transports. Messages are never
+// split, so a larger result is refused by SendResult with an error.
+const (
+	// MaxDatagramSize is the payload of a single udp datagram over IPv4.
+	MaxDatagramSize = 65507
+	// MaxFrameSize is the largest frame written or read over tcp and unix
+	// sockets.
+	MaxFrameSize = 1 << 20
+	// MaxWebhookSize is the largest body posted to webhooks.
+	MaxWebhookSize = 16 << 20
+)
+
+// Reporter sends the judge state to the host. SendStatus never blocks the
+// judge, SendResult returns once the result was delivered or given up on.
+type Reporter interface {
+	SendStatus(st *Status)
+	SendResult(result []byte) error
+	Close() error
+}
+
+type Options struct {
+	Uid          string
+	Ack          bool          // wait for the host to acknowledge every message
+	Retries      int           // resends of a message which was not acknowledged
+	Backoff      time.Duration // wait before the first resend, doubled every time
+	AckTimeout   time.Duration
+	Heartbeat    time.Duration // resend the last state when idle, 0 disables
+	FlushTimeout time.Duration // max time Close waits for queued messages
+	Key          []byte        // sign messages and check acks, see Sign
+}
+
+func (o *Options) withDefaults() Options {
+	ret := Options{}
+	if o != nil {
+		ret = *o
+	}
+	if ret.Uid == "" {
+		ret.Uid = util.RandSeq(12)
+	}
+	if ret.Retries < 0 {
+		ret.Retries = 0
+	}
+	if ret.Backoff <= 0 {
+		ret.Backoff = 200 * time.Millisecond
+	}
+	if ret.AckTimeout <= 0 {
+		ret.AckTimeout = time.Second
+	}
+	if ret.FlushTimeout <= 0 {
+		ret.FlushTimeout = 10 * time.Second
+	}
+	return ret
+}
+
+const maxBackoff = 10 * time.Second
+
+type transport interface {
+	// deliver sends msg encoded as data, when isAck is not nil it returns nil
+	// only after receiving an ack for which isAck holds.
+	deliver(data []byte, msg *message.StateMessage, isAck func(ack *message.Ack) bool, timeout time.Duration) error
+	// maxSize is the size of the largest message the transport carries.
+	maxSize() int
+	Close() error
+}
+
+type pending struct {
+	msg    *message.StateMessage
+	result chan error
+}
+
+// Client implements Reporter on top of a transport. Messages are delivered
+// one by one in the order they were sent, state messages waiting in the
+// queue are replaced by newer ones.
+type Client struct {
+	opts      Options
+	transport transport
+
+	mut    sync.Mutex
+	cond   *sync.Cond
+	queue  []*pending
+	seq    uint64
+	last   *message.StateMessage
+	closed bool
+
+	done chan struct{}
+	stop chan struct{}
+}
+
+func newClient(t transport, opts *Options) *Client {
+	c := &Client{
+		opts:      opts.withDefaults(),
+		transport: t,
+		done:      make(chan struct{}),
+		stop:      make(chan struct{}),
+	}
+	c.cond = sync.NewCond(&c.mut)
+	go c.loop()
+	if c.opts.Heartbeat > 0 {
+		go c.heartbeat()
+	}
+	return c
+}
+
+// Dial creates a Reporter from a target url: udp://host:port,
+// tcp://host:port, unix:///path/to/socket, or an http(s) webhook url.
+func Dial(target string, opts *Options) (Reporter, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "udp":
+		return NewUDP(u.Host, opts)
+	case "tcp":
+		return NewStream("tcp", u.Host, opts)
+	case "unix":
+		return NewStream("unix", u.Path, opts)
+	case "http", "https":
+		return NewWebhook(target, opts)
+	}
+	return nil, fmt.Errorf("unsupported host url ``%s''", target)
+}
+
+func (c *Client) SendStatus(st *Status) {
+	if c == nil {
+		return
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.closed {
+		return
+	}
+	msg := st.Message()
+	msg.Uid = c.opts.Uid
+	c.last = msg
+	msg = c.newMessage(message.MessageType_STATE)
+	if n := len(c.queue); n > 0 && c.queue[n-1].result == nil && c.queue[n-1].msg.Type == message.MessageType_STATE {
+		c.queue[n-1].msg = msg
+		return
+	}
+	c.queue = append(c.queue, &pending{msg: msg})
+	c.cond.Signal()
+}
+
+func (c *Client) SendResult(result []byte) error {
+	if c == nil {
+		return nil
+	}
+	c.mut.Lock()
+	if c.closed {
+		c.mut.Unlock()
+		return errors.New("reporter is closed")
+	}
+	p := &pending{
+		msg:    c.newMessage(message.MessageType_RESULT),
+		result: make(chan error, 1),
+	}
+	p.msg.Result = result
+	c.queue = append(c.queue, p)
+	c.cond.Signal()
+	c.mut.Unlock()
+	return <-p.result
+}
+
+// Close delivers the queued messages, waiting at most FlushTimeout, and
+// closes the connection.
+func (c *Client) Close() error {
+	if c == nil {
+		return nil
+	}
+	c.mut.Lock()
+	if c.closed {
+		c.mut.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.stop)
+	c.cond.Broadcast()
+	c.mut.Unlock()
+
+	select {
+	case <-c.done:
+	case <-time.After(c.opts.FlushTimeout):
+		logrus.Warnf("Timed out flushing messages to host")
+	}
+	return c.transport.Close()
+}
+
+func (c *Client) loop() {
+	defer close(c.done)
+	for {
+		c.mut.Lock()
+		for len(c.queue) == 0 && !c.closed {
+			c.cond.Wait()
+		}
+		if len(c.queue) == 0 {
+			c.mut.Unlock()
+			return
+		}
+		p := c.queue[0]
+		c.queue = c.queue[1:]
+		c.mut.Unlock()
+
+		err := c.deliver(p.msg)
+		if p.result != nil {
+			p.result <- err
+		} else if err != nil {
+			logrus.Errorf("Failed to deliver message %d to host: %v", p.msg.Seq, err)
+		}
+	}
+}
+
+// newMessage returns a copy of the last state with the next seq, c.mut must
+// be held.
+func (c *Client) newMessage(typ message.MessageType) *message.StateMessage {
+	c.seq++
+	msg := &message.StateMessage{
+		Uid: c.opts.Uid,
+	}
+	if c.last != nil {
+		*msg = *c.last
+	}
+	msg.Seq = c.seq
+	msg.Type = typ
+	return msg
+}
+
+func (c *Client) deliver(msg *message.StateMessage) error {
+	if len(c.opts.Key) > 0 {
+		if err := Sign(msg, c.opts.Key); err != nil {
+			return fmt.Errorf("failed to sign message: %v", err)
+		}
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+	if max := c.transport.maxSize(); len(data) > max {
+		return fmt.Errorf("message of %d bytes is larger than the %d bytes the transport carries", len(data), max)
+	}
+	var isAck func(ack *message.Ack) bool
+	if c.opts.Ack {
+		isAck = func(ack *message.Ack) bool {
+			if ack.Uid != msg.Uid || ack.Seq != msg.Seq {
+				return false
+			}
+			if len(c.opts.Key) > 0 && !CheckAckSignature(ack, c.opts.Key) {
+				logrus.Warnf("Ignoring ack of message %d with bad signature", msg.Seq)
+				return false
+			}
+			return true
+		}
+	}
+	attempts := 1 + c.opts.Retries
+	if msg.Type == message.MessageType_HEARTBEAT {
+		attempts = 1
+	}
+	backoff := c.opts.Backoff
+	for i := 0; ; i++ {
+		err = c.transport.deliver(data, msg, isAck, c.opts.AckTimeout)
+		if err == nil || i+1 >= attempts {
+			return err
+		}
+		logrus.Warnf("Failed to deliver message %d to host, retrying in %v: %v", msg.Seq, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (c *Client) heartbeat() {
+	ticker := time.NewTicker(c.opts.Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+		c.mut.Lock()
+		if len(c.queue) == 0 && !c.closed {
+			c.queue = append(c.queue, &pending{msg: c.newMessage(message.MessageType_HEARTBEAT)})
+			c.cond.Signal()
+		}
+		c.mut.Unlock()
+	}
+}
+
+// waitAck reads acks with read until one for which isAck holds arrives.
+func waitAck(read func() ([]byte, error), isAck func(ack *message.Ack) bool) error {
+	for {
+		data, err := read()
+		if err != nil {
+			return err
+		}
+		ack := &message.Ack{}
+		if err := proto.Unmarshal(data, ack); err != nil {
+			logrus.Warnf("Ignoring malformed ack: %v", err)
+			continue
+		}
+		if isAck(ack) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package hostconn_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/collector"
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn"
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn/message"
+)
+
+func TestReportToCollector(t *testing.T) {
+	key := []byte("secret")
+	tests := []struct {
+		name   string
+		max    int
+		listen func(c *collector.Collector) (string, error)
+	}{
+		{"udp", hostconn.MaxDatagramSize, func(c *collector.Collector) (string, error) {
+			addr, err := c.ListenUDP("127.0.0.1:0")
+			if err != nil {
+				return "", err
+			}
+			return "udp://" + addr.String(), nil
+		}},
+		{"tcp", hostconn.MaxFrameSize, func(c *collector.Collector) (string, error) {
+			addr, err := c.ListenStream("tcp", "127.0.0.1:0")
+			if err != nil {
+				return "", err
+			}
+			return "tcp://" + addr.String(), nil
+		}},
+	}
+	for _, tt := range tests {
+		c := collector.New(&collector.Config{Key: key})
+		target, err := tt.listen(c)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		host, err := hostconn.Dial(target, &hostconn.Options{Uid: tt.name, Ack: true, Key: key})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		host.SendStatus(&hostconn.Status{Phase: message.Phase_PREPARING})
+		host.SendStatus(&hostconn.Status{Phase: message.Phase_RUNNING, Test: 1, Total: 2})
+		host.SendStatus(&hostconn.Status{Phase: message.Phase_FINISHED, Progress: 100, Total: 2, Verdict: "AC"})
+		if err := host.SendResult([]byte(`{"verdict":"AC"}`)); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		// messages are not split, a result larger than the transport carries
+		// is refused
+		if err := host.SendResult(bytes.Repeat([]byte("x"), tt.max)); err == nil {
+			t.Errorf("%s: result of %d bytes was sent", tt.name, tt.max)
+		}
+		host.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		j, err := c.WaitFinished(ctx, tt.name)
+		cancel()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if j.Verdict != "AC" || j.Total != 2 || j.Rejected != 0 {
+			t.Errorf("%s: got %+v", tt.name, j)
+		}
+		if string(j.Result) != `{"verdict":"AC"}` {
+			t.Errorf("%s: result %q", tt.name, j.Result)
+		}
+		c.Close()
+	}
+}
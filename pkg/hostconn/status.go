@@ -0,0 +1,168 @@
+/* hostconn/status.go
+ * Typed judge phases, and a state machine decoding them on the host side
+ */
+
+package hostconn
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn/message"
+)
+
+// Status is the state of a judgement as reported to the host.
+type Status struct {
+	Phase    message.Phase
+	Progress int // percentage of judged test cases
+	Test     int // 1-based number of the test case being run or checked
+	Total    int
+	Verdict  string // first non-AC verdict so far, or the final verdict
+}
+
+// Message returns st as a state message, without uid nor seq.
+func (st *Status) Message() *message.StateMessage {
+	return &message.StateMessage{
+		State:    st.legacyState(),
+		Progress: int32(st.Progress),
+		Phase:    st.Phase,
+		Test:     int32(st.Test),
+		Total:    int32(st.Total),
+		Verdict:  st.Verdict,
+	}
+}
+
+// legacyState returns the status code sent before phases were introduced.
+func (st *Status) legacyState() string {
+	switch st.Phase {
+	case message.Phase_FETCHING_PROBLEM, message.Phase_PREPARING:
+		return "00"
+	case message.Phase_COMPILING:
+		return "01"
+	case message.Phase_RUNNING, message.Phase_CHECKING:
+		if st.Test == 0 {
+			return "02"
+		}
+		return "10"
+	case message.Phase_FINISHED, message.Phase_FAILED:
+		return "FF"
+	}
+	return ""
+}
+
+// ErrStale is returned by Machine.Apply for messages older than the last one
+// applied, e.g. retransmissions.
+var ErrStale = errors.New("stale message")
+
+type TransitionError struct {
+	From message.Phase
+	To   message.Phase
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("illegal transition from %v to %v", e.From, e.To)
+}
+
+// phaseOrder ranks the phases, a judge never goes back to a lower rank.
+// RUNNING and CHECKING share a rank since they alternate for every test.
+var phaseOrder = map[message.Phase]int{
+	message.Phase_UNKNOWN:          0,
+	message.Phase_FETCHING_PROBLEM: 1,
+	message.Phase_PREPARING:        2,
+	message.Phase_COMPILING:        3,
+	message.Phase_RUNNING:          4,
+	message.Phase_CHECKING:         4,
+	message.Phase_FINISHED:         5,
+	message.Phase_FAILED:           5,
+}
+
+// LegalTransition reports whether a judge may go from phase from to phase to.
+// Phases may be skipped, since reporters coalesce state messages.
+func LegalTransition(from, to message.Phase) bool {
+	if from == to {
+		return true
+	}
+	if Terminal(from) {
+		return false
+	}
+	if to == message.Phase_FAILED {
+		return true
+	}
+	rankFrom, ok := phaseOrder[from]
+	if !ok {
+		return false
+	}
+	rankTo, ok := phaseOrder[to]
+	if !ok {
+		return false
+	}
+	return rankTo >= rankFrom && to != message.Phase_UNKNOWN
+}
+
+func Terminal(phase message.Phase) bool {
+	return phase == message.Phase_FINISHED || phase == message.Phase_FAILED
+}
+
+// Machine is the state of one judge, built from the messages it sent.
+type Machine struct {
+	Uid      string        `json:"uid"`
+	Phase    message.Phase `json:"-"`
+	Name     string        `json:"phase"`
+	State    string        `json:"state"`
+	Progress int           `json:"progress"`
+	Test     int           `json:"test"`
+	Total    int           `json:"total"`
+	Verdict  string        `json:"verdict,omitempty"`
+	Seq      uint64        `json:"seq"`
+	Result   []byte        `json:"-"`
+	Updated  time.Time     `json:"updated"`
+}
+
+func NewMachine(uid string) *Machine {
+	return &Machine{
+		Uid: uid,
+	}
+}
+
+// Apply validates msg against the current state and applies it. Messages with
+// a seq not greater than the last applied one return ErrStale, illegal phase
+// changes return a *TransitionError, in both cases the state is unchanged.
+func (m *Machine) Apply(msg *message.StateMessage) error {
+	if msg.Uid != m.Uid {
+		return fmt.Errorf("message of ``%s'' applied to ``%s''", msg.Uid, m.Uid)
+	}
+	if m.Seq != 0 && msg.Seq <= m.Seq {
+		return ErrStale
+	}
+	if !LegalTransition(m.Phase, msg.Phase) {
+		return &TransitionError{From: m.Phase, To: msg.Phase}
+	}
+	if int(msg.Progress) < m.Progress && m.Phase == msg.Phase {
+		return fmt.Errorf("progress went back from %d to %d", m.Progress, msg.Progress)
+	}
+	m.Phase = msg.Phase
+	m.Name = msg.Phase.String()
+	m.State = msg.State
+	m.Progress = int(msg.Progress)
+	m.Test = int(msg.Test)
+	m.Total = int(msg.Total)
+	m.Verdict = msg.Verdict
+	m.Seq = msg.Seq
+	if msg.Type == message.MessageType_RESULT {
+		m.Result = msg.Result
+	}
+	m.Updated = time.Now()
+	return nil
+}
+
+// Decode unmarshals a StateMessage sent by a Reporter.
+func Decode(data []byte) (*message.StateMessage, error) {
+	msg := &message.StateMessage{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,160 @@
+package hostconn
+
+import (
+	"testing"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn/message"
+)
+
+func TestLegalTransition(t *testing.T) {
+	tests := []struct {
+		from, to message.Phase
+		legal    bool
+	}{
+		{message.Phase_UNKNOWN, message.Phase_FETCHING_PROBLEM, true},
+		{message.Phase_FETCHING_PROBLEM, message.Phase_PREPARING, true},
+		{message.Phase_UNKNOWN, message.Phase_RUNNING, true},
+		{message.Phase_PREPARING, message.Phase_COMPILING, true},
+		{message.Phase_COMPILING, message.Phase_RUNNING, true},
+		{message.Phase_RUNNING, message.Phase_CHECKING, true},
+		{message.Phase_CHECKING, message.Phase_RUNNING, true},
+		{message.Phase_CHECKING, message.Phase_FINISHED, true},
+		{message.Phase_PREPARING, message.Phase_FAILED, true},
+		{message.Phase_RUNNING, message.Phase_RUNNING, true},
+		{message.Phase_FINISHED, message.Phase_FINISHED, true},
+		{message.Phase_COMPILING, message.Phase_PREPARING, false},
+		{message.Phase_RUNNING, message.Phase_FETCHING_PROBLEM, false},
+		{message.Phase_RUNNING, message.Phase_UNKNOWN, false},
+		{message.Phase_FINISHED, message.Phase_RUNNING, false},
+		{message.Phase_FINISHED, message.Phase_FAILED, false},
+		{message.Phase_FAILED, message.Phase_FINISHED, false},
+		{message.Phase_RUNNING, message.Phase(42), false},
+	}
+	for _, tt := range tests {
+		if got := LegalTransition(tt.from, tt.to); got != tt.legal {
+			t.Errorf("LegalTransition(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.legal)
+		}
+	}
+}
+
+func TestLegacyState(t *testing.T) {
+	tests := []struct {
+		st   Status
+		want string
+	}{
+		{Status{Phase: message.Phase_UNKNOWN}, ""},
+		{Status{Phase: message.Phase_FETCHING_PROBLEM}, "00"},
+		{Status{Phase: message.Phase_PREPARING}, "00"},
+		{Status{Phase: message.Phase_COMPILING}, "01"},
+		{Status{Phase: message.Phase_RUNNING}, "02"},
+		{Status{Phase: message.Phase_RUNNING, Test: 1}, "10"},
+		{Status{Phase: message.Phase_CHECKING, Test: 3}, "10"},
+		{Status{Phase: message.Phase_FINISHED}, "FF"},
+		{Status{Phase: message.Phase_FAILED}, "FF"},
+	}
+	for _, tt := range tests {
+		if got := tt.st.Message().State; got != tt.want {
+			t.Errorf("state of %+v = %q, want %q", tt.st, got, tt.want)
+		}
+	}
+}
+
+func TestMachineApply(t *testing.T) {
+	msg := func(seq uint64, phase message.Phase, progress int32) *message.StateMessage {
+		return &message.StateMessage{Uid: "judge", Seq: seq, Phase: phase, Progress: progress}
+	}
+	tests := []struct {
+		name  string
+		msgs  []*message.StateMessage
+		err   string // error of the last message, empty for none
+		phase message.Phase
+		seq   uint64
+	}{
+		{
+			name:  "in order",
+			msgs:  []*message.StateMessage{msg(1, message.Phase_FETCHING_PROBLEM, 0), msg(2, message.Phase_PREPARING, 0), msg(3, message.Phase_RUNNING, 50), msg(4, message.Phase_FINISHED, 100)},
+			phase: message.Phase_FINISHED,
+			seq:   4,
+		},
+		{
+			name:  "skipped seq",
+			msgs:  []*message.StateMessage{msg(1, message.Phase_PREPARING, 0), msg(5, message.Phase_COMPILING, 0)},
+			phase: message.Phase_COMPILING,
+			seq:   5,
+		},
+		{
+			name:  "retransmission",
+			msgs:  []*message.StateMessage{msg(1, message.Phase_PREPARING, 0), msg(2, message.Phase_COMPILING, 0), msg(2, message.Phase_COMPILING, 0)},
+			err:   ErrStale.Error(),
+			phase: message.Phase_COMPILING,
+			seq:   2,
+		},
+		{
+			name:  "reordered",
+			msgs:  []*message.StateMessage{msg(2, message.Phase_COMPILING, 0), msg(1, message.Phase_PREPARING, 0)},
+			err:   ErrStale.Error(),
+			phase: message.Phase_COMPILING,
+			seq:   2,
+		},
+		{
+			name:  "going back",
+			msgs:  []*message.StateMessage{msg(1, message.Phase_RUNNING, 0), msg(2, message.Phase_COMPILING, 0)},
+			err:   "illegal transition from RUNNING to COMPILING",
+			phase: message.Phase_RUNNING,
+			seq:   1,
+		},
+		{
+			name:  "after finished",
+			msgs:  []*message.StateMessage{msg(1, message.Phase_FINISHED, 100), msg(2, message.Phase_FAILED, 100)},
+			err:   "illegal transition from FINISHED to FAILED",
+			phase: message.Phase_FINISHED,
+			seq:   1,
+		},
+		{
+			name:  "progress back",
+			msgs:  []*message.StateMessage{msg(1, message.Phase_RUNNING, 50), msg(2, message.Phase_RUNNING, 25)},
+			err:   "progress went back from 50 to 25",
+			phase: message.Phase_RUNNING,
+			seq:   1,
+		},
+		{
+			name:  "other uid",
+			msgs:  []*message.StateMessage{{Uid: "other", Seq: 1, Phase: message.Phase_PREPARING}},
+			err:   "message of ``other'' applied to ``judge''",
+			phase: message.Phase_UNKNOWN,
+		},
+	}
+	for _, tt := range tests {
+		m := NewMachine("judge")
+		var err error
+		for _, msg := range tt.msgs {
+			err = m.Apply(msg)
+		}
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.err {
+			t.Errorf("%s: error %q, want %q", tt.name, got, tt.err)
+		}
+		if m.Phase != tt.phase || m.Seq != tt.seq {
+			t.Errorf("%s: at %v seq %d, want %v seq %d", tt.name, m.Phase, m.Seq, tt.phase, tt.seq)
+		}
+	}
+}
+
+func TestMachineResult(t *testing.T) {
+	m := NewMachine("judge")
+	if err := m.Apply(&message.StateMessage{Uid: "judge", Seq: 1, Phase: message.Phase_FINISHED, Type: message.MessageType_RESULT, Result: []byte("{}")}); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.Result) != "{}" {
+		t.Errorf("result %q was not kept", m.Result)
+	}
+	if err := m.Apply(&message.StateMessage{Uid: "judge", Seq: 2, Phase: message.Phase_FINISHED, Type: message.MessageType_HEARTBEAT}); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.Result) != "{}" {
+		t.Errorf("result was overwritten by a heartbeat")
+	}
+}
@@ -0,0 +1,104 @@
+/* hostconn/stream.go
+ * Report current judge state to some host, over tcp or unix socket
+ */
+
+package hostconn
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn/message"
+)
+
+type streamTransport struct {
+	network string
+	addr    string
+	conn    net.Conn
+}
+
+// NewStream reports to a tcp or unix socket. Every message, in both
+// directions, is framed by its length as a 4 byte big endian integer. The
+// connection is established lazily and re-established after errors.
+func NewStream(network, addr string, opts *Options) (*Client, error) {
+	if network != "tcp" && network != "unix" {
+		return nil, fmt.Errorf("unsupported network %s", network)
+	}
+	return newClient(&streamTransport{
+		network: network,
+		addr:    addr,
+	}, opts), nil
+}
+
+func (t *streamTransport) deliver(data []byte, msg *message.StateMessage, isAck func(ack *message.Ack) bool, timeout time.Duration) error {
+	if t.conn == nil {
+		conn, err := net.DialTimeout(t.network, t.addr, timeout)
+		if err != nil {
+			return err
+		}
+		t.conn = conn
+	}
+	err := t.roundTrip(data, isAck, timeout)
+	if err != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+	return err
+}
+
+func (t *streamTransport) roundTrip(data []byte, isAck func(ack *message.Ack) bool, timeout time.Duration) error {
+	t.conn.SetDeadline(time.Now().Add(timeout))
+	if err := WriteFrame(t.conn, data); err != nil {
+		return err
+	}
+	if isAck == nil {
+		return nil
+	}
+	return waitAck(func() ([]byte, error) {
+		return ReadFrame(t.conn)
+	}, isAck)
+}
+
+func (t *streamTransport) maxSize() int {
+	return MaxFrameSize
+}
+
+func (t *streamTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Close()
+}
+
+// WriteFrame writes data prefixed by its length, data is at most
+// MaxFrameSize bytes.
+func WriteFrame(w io.Writer, data []byte) error {
+	if len(data) > MaxFrameSize {
+		return fmt.Errorf("frame of %d bytes is too large", len(data))
+	}
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	_, err := w.Write(frame)
+	return err
+}
+
+// ReadFrame reads one frame written by WriteFrame.
+func ReadFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size > MaxFrameSize {
+		return nil, fmt.Errorf("frame of %d bytes is too large", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -5,54 +5,55 @@
 package hostconn
 
 import (
-	"fmt"
 	"net"
-
-	"github.com/golang/protobuf/proto"
+	"time"
 
 	"github.com/erjiaqing/PCIJudger2/pkg/hostconn/message"
-	"github.com/erjiaqing/PCIJudger2/pkg/util"
-	"github.com/sirupsen/logrus"
 )
 
-type UDP struct {
+type udpTransport struct {
 	socket *net.UDPConn
-	uid    string
+	buf    []byte
 }
 
-func NewUDP(ip string, port int, uid string) *UDP {
-	if ip == "" || port == 0 {
-		return &UDP{}
-	}
-	if uid == "" {
-		uid = util.RandSeq(12)
-	}
-	udpaddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", ip, port))
+// NewUDP reports to addr in udp datagrams, one message per datagram. Acks are
+// expected as datagrams sent back to the source address.
+func NewUDP(addr string, opts *Options) (*Client, error) {
+	udpaddr, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
-		logrus.Fatalf("Failed to resolve address of udp host: %v", err)
+		return nil, err
 	}
-	addr, err := net.DialUDP("udp", nil, udpaddr)
+	conn, err := net.DialUDP("udp", nil, udpaddr)
 	if err != nil {
-		logrus.Fatalf("Failed to create connection to udp host: %v", err)
-	}
-	return &UDP{
-		socket: addr,
-		uid:    uid,
+		return nil, err
 	}
+	return newClient(&udpTransport{
+		socket: conn,
+		buf:    make([]byte, 65536),
+	}, opts), nil
 }
 
-func (c *UDP) SendStatus(state string, progress int) {
-	if c == nil || c.socket == nil {
-		return
+func (t *udpTransport) deliver(data []byte, msg *message.StateMessage, isAck func(ack *message.Ack) bool, timeout time.Duration) error {
+	if _, err := t.socket.Write(data); err != nil {
+		return err
 	}
-	msg := &message.StateMessage{}
-	msg.Uid = c.uid
-	msg.State = state
-	msg.Progress = int32(progress)
-
-	data, err := proto.Marshal(msg)
-	if err != nil {
-		logrus.Errorf("Failed to marshal message: %v", data)
+	if isAck == nil {
+		return nil
 	}
-	c.socket.Write([]byte(data))
+	t.socket.SetReadDeadline(time.Now().Add(timeout))
+	return waitAck(func() ([]byte, error) {
+		n, err := t.socket.Read(t.buf)
+		if err != nil {
+			return nil, err
+		}
+		return t.buf[:n], nil
+	}, isAck)
+}
+
+func (t *udpTransport) maxSize() int {
+	return MaxDatagramSize
+}
+
+func (t *udpTransport) Close() error {
+	return t.socket.Close()
 }
@@ -0,0 +1,60 @@
+/* hostconn/webhook.go
+ * Report current judge state to some host, by http POST
+ */
+
+package hostconn
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn/message"
+)
+
+type webhookTransport struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook posts every message to url as application/x-protobuf. Any 2xx
+// response acknowledges the message.
+func NewWebhook(url string, opts *Options) (*Client, error) {
+	return newClient(&webhookTransport{
+		url:    url,
+		client: &http.Client{},
+	}, opts), nil
+}
+
+func (t *webhookTransport) deliver(data []byte, msg *message.StateMessage, isAck func(ack *message.Ack) bool, timeout time.Duration) error {
+	req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Judge-Uid", msg.Uid)
+	req.Header.Set("X-Judge-Seq", strconv.FormatUint(msg.Seq, 10))
+	t.client.Timeout = timeout
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("host responded %s", resp.Status)
+	}
+	return nil
+}
+
+func (t *webhookTransport) maxSize() int {
+	return MaxWebhookSize
+}
+
+func (t *webhookTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,57 @@
+package judgerpc
+
+import (
+	"github.com/erjiaqing/PCIJudger2/pkg/pci15"
+)
+
+func DetailToProto(d *pci15.JudgeDetail) *JudgeDetail {
+	if d == nil {
+		return nil
+	}
+	return &JudgeDetail{
+		Name:       d.Name,
+		Input:      d.Input,
+		Output:     d.Output,
+		Answer:     d.Answer,
+		Comment:    d.Comment,
+		Score:      int32(d.Score),
+		Verdict:    d.Verdict,
+		ExeTime:    d.ExeTime,
+		ExeMemory:  d.ExeMemory,
+		ExitCode:   d.ExitCode,
+		ExitSignal: d.ExitSignal,
+	}
+}
+
+func ResultToProto(r *pci15.JudgeResult) *JudgeResult {
+	if r == nil {
+		return nil
+	}
+	ret := &JudgeResult{
+		Success:   r.Success,
+		Verdict:   r.Verdict,
+		ExeTime:   r.ExeTime,
+		ExeMemory: r.ExeMemory,
+		ExitCode:  r.ExitCode,
+		UsedTime:  r.UsedTime,
+		Score:     int32(r.Score),
+		FullScore: int32(r.FullScore),
+	}
+	for _, d := range r.Detail {
+		ret.Detail = append(ret.Detail, DetailToProto(d))
+	}
+	return ret
+}
+
+func EventToProto(ev *pci15.JudgeEvent) *JudgeEvent {
+	return &JudgeEvent{
+		Type:   string(ev.Type),
+		Time:   ev.Time.UnixNano(),
+		Test:   int32(ev.Test),
+		Judged: int32(ev.Judged),
+		Total:  int32(ev.Total),
+		Detail: DetailToProto(ev.Detail),
+		Result: ResultToProto(ev.Result),
+		Error:  ev.Error,
+	}
+}
@@ -0,0 +1,840 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: judge.proto
+
+package judgerpc
+
+import (
+	context "context"
+	fmt "fmt"
+	message "github.com/erjiaqing/PCIJudger2/pkg/hostconn/message"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type SubmitRequest struct {
+	Source               string   `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Lang                 string   `protobuf:"bytes,2,opt,name=lang,proto3" json:"lang,omitempty"`
+	Problem              string   `protobuf:"bytes,3,opt,name=problem,proto3" json:"problem,omitempty"`
+	Version              string   `protobuf:"bytes,4,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubmitRequest) Reset()         { *m = SubmitRequest{} }
+func (m *SubmitRequest) String() string { return proto.CompactTextString(m) }
+func (*SubmitRequest) ProtoMessage()    {}
+func (*SubmitRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a2b4fa635fb2baaa, []int{0}
+}
+
+func (m *SubmitRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubmitRequest.Unmarshal(m, b)
+}
+func (m *SubmitRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubmitRequest.Marshal(b, m, deterministic)
+}
+func (m *SubmitRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubmitRequest.Merge(m, src)
+}
+func (m *SubmitRequest) XXX_Size() int {
+	return xxx_messageInfo_SubmitRequest.Size(m)
+}
+func (m *SubmitRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubmitRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubmitRequest proto.InternalMessageInfo
+
+func (m *SubmitRequest) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
+}
+
+func (m *SubmitRequest) GetLang() string {
+	if m != nil {
+		return m.Lang
+	}
+	return ""
+}
+
+func (m *SubmitRequest) GetProblem() string {
+	if m != nil {
+		return m.Problem
+	}
+	return ""
+}
+
+func (m *SubmitRequest) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+type SubmitResponse struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubmitResponse) Reset()         { *m = SubmitResponse{} }
+func (m *SubmitResponse) String() string { return proto.CompactTextString(m) }
+func (*SubmitResponse) ProtoMessage()    {}
+func (*SubmitResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a2b4fa635fb2baaa, []int{1}
+}
+
+func (m *SubmitResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubmitResponse.Unmarshal(m, b)
+}
+func (m *SubmitResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubmitResponse.Marshal(b, m, deterministic)
+}
+func (m *SubmitResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubmitResponse.Merge(m, src)
+}
+func (m *SubmitResponse) XXX_Size() int {
+	return xxx_messageInfo_SubmitResponse.Size(m)
+}
+func (m *SubmitResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubmitResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubmitResponse proto.InternalMessageInfo
+
+func (m *SubmitResponse) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type WatchRequest struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchRequest) Reset()         { *m = WatchRequest{} }
+func (m *WatchRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchRequest) ProtoMessage()    {}
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a2b4fa635fb2baaa, []int{2}
+}
+
+func (m *WatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchRequest.Unmarshal(m, b)
+}
+func (m *WatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchRequest.Marshal(b, m, deterministic)
+}
+func (m *WatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchRequest.Merge(m, src)
+}
+func (m *WatchRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchRequest.Size(m)
+}
+func (m *WatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchRequest proto.InternalMessageInfo
+
+func (m *WatchRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type GetResultRequest struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetResultRequest) Reset()         { *m = GetResultRequest{} }
+func (m *GetResultRequest) String() string { return proto.CompactTextString(m) }
+func (*GetResultRequest) ProtoMessage()    {}
+func (*GetResultRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a2b4fa635fb2baaa, []int{3}
+}
+
+func (m *GetResultRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetResultRequest.Unmarshal(m, b)
+}
+func (m *GetResultRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetResultRequest.Marshal(b, m, deterministic)
+}
+func (m *GetResultRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetResultRequest.Merge(m, src)
+}
+func (m *GetResultRequest) XXX_Size() int {
+	return xxx_messageInfo_GetResultRequest.Size(m)
+}
+func (m *GetResultRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetResultRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetResultRequest proto.InternalMessageInfo
+
+func (m *GetResultRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type GetResultResponse struct {
+	Id                   string       `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status               string       `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Judged               int32        `protobuf:"varint,3,opt,name=judged,proto3" json:"judged,omitempty"`
+	Total                int32        `protobuf:"varint,4,opt,name=total,proto3" json:"total,omitempty"`
+	Error                string       `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	Result               *JudgeResult `protobuf:"bytes,6,opt,name=result,proto3" json:"result,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *GetResultResponse) Reset()         { *m = GetResultResponse{} }
+func (m *GetResultResponse) String() string { return proto.CompactTextString(m) }
+func (*GetResultResponse) ProtoMessage()    {}
+func (*GetResultResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a2b4fa635fb2baaa, []int{4}
+}
+
+func (m *GetResultResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetResultResponse.Unmarshal(m, b)
+}
+func (m *GetResultResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetResultResponse.Marshal(b, m, deterministic)
+}
+func (m *GetResultResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetResultResponse.Merge(m, src)
+}
+func (m *GetResultResponse) XXX_Size() int {
+	return xxx_messageInfo_GetResultResponse.Size(m)
+}
+func (m *GetResultResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetResultResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetResultResponse proto.InternalMessageInfo
+
+func (m *GetResultResponse) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *GetResultResponse) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *GetResultResponse) GetJudged() int32 {
+	if m != nil {
+		return m.Judged
+	}
+	return 0
+}
+
+func (m *GetResultResponse) GetTotal() int32 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+func (m *GetResultResponse) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *GetResultResponse) GetResult() *JudgeResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+type JudgeEvent struct {
+	Type   string       `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Time   int64        `protobuf:"varint,2,opt,name=time,proto3" json:"time,omitempty"`
+	Test   int32        `protobuf:"varint,3,opt,name=test,proto3" json:"test,omitempty"`
+	Judged int32        `protobuf:"varint,4,opt,name=judged,proto3" json:"judged,omitempty"`
+	Total  int32        `protobuf:"varint,5,opt,name=total,proto3" json:"total,omitempty"`
+	Detail *JudgeDetail `protobuf:"bytes,6,opt,name=detail,proto3" json:"detail,omitempty"`
+	Result *JudgeResult `protobuf:"bytes,7,opt,name=result,proto3" json:"result,omitempty"`
+	Error  string       `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	// state is the event as reported by judgers to the host, see host.proto
+	State                *message.StateMessage `protobuf:"bytes,9,opt,name=state,proto3" json:"state,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
+	XXX_unrecognized     []byte                `json:"-"`
+	XXX_sizecache        int32                 `json:"-"`
+}
+
+func (m *JudgeEvent) Reset()         { *m = JudgeEvent{} }
+func (m *JudgeEvent) String() string { return proto.CompactTextString(m) }
+func (*JudgeEvent) ProtoMessage()    {}
+func (*JudgeEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a2b4fa635fb2baaa, []int{5}
+}
+
+func (m *JudgeEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_JudgeEvent.Unmarshal(m, b)
+}
+func (m *JudgeEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_JudgeEvent.Marshal(b, m, deterministic)
+}
+func (m *JudgeEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_JudgeEvent.Merge(m, src)
+}
+func (m *JudgeEvent) XXX_Size() int {
+	return xxx_messageInfo_JudgeEvent.Size(m)
+}
+func (m *JudgeEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_JudgeEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_JudgeEvent proto.InternalMessageInfo
+
+func (m *JudgeEvent) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *JudgeEvent) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+func (m *JudgeEvent) GetTest() int32 {
+	if m != nil {
+		return m.Test
+	}
+	return 0
+}
+
+func (m *JudgeEvent) GetJudged() int32 {
+	if m != nil {
+		return m.Judged
+	}
+	return 0
+}
+
+func (m *JudgeEvent) GetTotal() int32 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+func (m *JudgeEvent) GetDetail() *JudgeDetail {
+	if m != nil {
+		return m.Detail
+	}
+	return nil
+}
+
+func (m *JudgeEvent) GetResult() *JudgeResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (m *JudgeEvent) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *JudgeEvent) GetState() *message.StateMessage {
+	if m != nil {
+		return m.State
+	}
+	return nil
+}
+
+type JudgeResult struct {
+	Success              bool           `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Verdict              string         `protobuf:"bytes,2,opt,name=verdict,proto3" json:"verdict,omitempty"`
+	ExeTime              float32        `protobuf:"fixed32,3,opt,name=exe_time,json=exeTime,proto3" json:"exe_time,omitempty"`
+	ExeMemory            uint64         `protobuf:"varint,4,opt,name=exe_memory,json=exeMemory,proto3" json:"exe_memory,omitempty"`
+	ExitCode             int32          `protobuf:"varint,5,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	UsedTime             uint64         `protobuf:"varint,6,opt,name=used_time,json=usedTime,proto3" json:"used_time,omitempty"`
+	Score                int32          `protobuf:"varint,7,opt,name=score,proto3" json:"score,omitempty"`
+	FullScore            int32          `protobuf:"varint,8,opt,name=full_score,json=fullScore,proto3" json:"full_score,omitempty"`
+	Detail               []*JudgeDetail `protobuf:"bytes,9,rep,name=detail,proto3" json:"detail,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *JudgeResult) Reset()         { *m = JudgeResult{} }
+func (m *JudgeResult) String() string { return proto.CompactTextString(m) }
+func (*JudgeResult) ProtoMessage()    {}
+func (*JudgeResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a2b4fa635fb2baaa, []int{6}
+}
+
+func (m *JudgeResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_JudgeResult.Unmarshal(m, b)
+}
+func (m *JudgeResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_JudgeResult.Marshal(b, m, deterministic)
+}
+func (m *JudgeResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_JudgeResult.Merge(m, src)
+}
+func (m *JudgeResult) XXX_Size() int {
+	return xxx_messageInfo_JudgeResult.Size(m)
+}
+func (m *JudgeResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_JudgeResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_JudgeResult proto.InternalMessageInfo
+
+func (m *JudgeResult) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+func (m *JudgeResult) GetVerdict() string {
+	if m != nil {
+		return m.Verdict
+	}
+	return ""
+}
+
+func (m *JudgeResult) GetExeTime() float32 {
+	if m != nil {
+		return m.ExeTime
+	}
+	return 0
+}
+
+func (m *JudgeResult) GetExeMemory() uint64 {
+	if m != nil {
+		return m.ExeMemory
+	}
+	return 0
+}
+
+func (m *JudgeResult) GetExitCode() int32 {
+	if m != nil {
+		return m.ExitCode
+	}
+	return 0
+}
+
+func (m *JudgeResult) GetUsedTime() uint64 {
+	if m != nil {
+		return m.UsedTime
+	}
+	return 0
+}
+
+func (m *JudgeResult) GetScore() int32 {
+	if m != nil {
+		return m.Score
+	}
+	return 0
+}
+
+func (m *JudgeResult) GetFullScore() int32 {
+	if m != nil {
+		return m.FullScore
+	}
+	return 0
+}
+
+func (m *JudgeResult) GetDetail() []*JudgeDetail {
+	if m != nil {
+		return m.Detail
+	}
+	return nil
+}
+
+type JudgeDetail struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Input                string   `protobuf:"bytes,2,opt,name=input,proto3" json:"input,omitempty"`
+	Output               string   `protobuf:"bytes,3,opt,name=output,proto3" json:"output,omitempty"`
+	Answer               string   `protobuf:"bytes,4,opt,name=answer,proto3" json:"answer,omitempty"`
+	Comment              string   `protobuf:"bytes,5,opt,name=comment,proto3" json:"comment,omitempty"`
+	Score                int32    `protobuf:"varint,6,opt,name=score,proto3" json:"score,omitempty"`
+	Verdict              string   `protobuf:"bytes,7,opt,name=verdict,proto3" json:"verdict,omitempty"`
+	ExeTime              float32  `protobuf:"fixed32,8,opt,name=exe_time,json=exeTime,proto3" json:"exe_time,omitempty"`
+	ExeMemory            uint64   `protobuf:"varint,9,opt,name=exe_memory,json=exeMemory,proto3" json:"exe_memory,omitempty"`
+	ExitCode             int32    `protobuf:"varint,10,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	ExitSignal           int32    `protobuf:"varint,11,opt,name=exit_signal,json=exitSignal,proto3" json:"exit_signal,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *JudgeDetail) Reset()         { *m = JudgeDetail{} }
+func (m *JudgeDetail) String() string { return proto.CompactTextString(m) }
+func (*JudgeDetail) ProtoMessage()    {}
+func (*JudgeDetail) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a2b4fa635fb2baaa, []int{7}
+}
+
+func (m *JudgeDetail) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_JudgeDetail.Unmarshal(m, b)
+}
+func (m *JudgeDetail) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_JudgeDetail.Marshal(b, m, deterministic)
+}
+func (m *JudgeDetail) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_JudgeDetail.Merge(m, src)
+}
+func (m *JudgeDetail) XXX_Size() int {
+	return xxx_messageInfo_JudgeDetail.Size(m)
+}
+func (m *JudgeDetail) XXX_DiscardUnknown() {
+	xxx_messageInfo_JudgeDetail.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_JudgeDetail proto.InternalMessageInfo
+
+func (m *JudgeDetail) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *JudgeDetail) GetInput() string {
+	if m != nil {
+		return m.Input
+	}
+	return ""
+}
+
+func (m *JudgeDetail) GetOutput() string {
+	if m != nil {
+		return m.Output
+	}
+	return ""
+}
+
+func (m *JudgeDetail) GetAnswer() string {
+	if m != nil {
+		return m.Answer
+	}
+	return ""
+}
+
+func (m *JudgeDetail) GetComment() string {
+	if m != nil {
+		return m.Comment
+	}
+	return ""
+}
+
+func (m *JudgeDetail) GetScore() int32 {
+	if m != nil {
+		return m.Score
+	}
+	return 0
+}
+
+func (m *JudgeDetail) GetVerdict() string {
+	if m != nil {
+		return m.Verdict
+	}
+	return ""
+}
+
+func (m *JudgeDetail) GetExeTime() float32 {
+	if m != nil {
+		return m.ExeTime
+	}
+	return 0
+}
+
+func (m *JudgeDetail) GetExeMemory() uint64 {
+	if m != nil {
+		return m.ExeMemory
+	}
+	return 0
+}
+
+func (m *JudgeDetail) GetExitCode() int32 {
+	if m != nil {
+		return m.ExitCode
+	}
+	return 0
+}
+
+func (m *JudgeDetail) GetExitSignal() int32 {
+	if m != nil {
+		return m.ExitSignal
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*SubmitRequest)(nil), "judgerpc.SubmitRequest")
+	proto.RegisterType((*SubmitResponse)(nil), "judgerpc.SubmitResponse")
+	proto.RegisterType((*WatchRequest)(nil), "judgerpc.WatchRequest")
+	proto.RegisterType((*GetResultRequest)(nil), "judgerpc.GetResultRequest")
+	proto.RegisterType((*GetResultResponse)(nil), "judgerpc.GetResultResponse")
+	proto.RegisterType((*JudgeEvent)(nil), "judgerpc.JudgeEvent")
+	proto.RegisterType((*JudgeResult)(nil), "judgerpc.JudgeResult")
+	proto.RegisterType((*JudgeDetail)(nil), "judgerpc.JudgeDetail")
+}
+
+func init() { proto.RegisterFile("judge.proto", fileDescriptor_a2b4fa635fb2baaa) }
+
+var fileDescriptor_a2b4fa635fb2baaa = []byte{
+	// 627 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0xc1, 0x6e, 0xd3, 0x4c,
+	0x10, 0x96, 0xdd, 0xd8, 0xb1, 0x27, 0xff, 0x5f, 0xc1, 0xaa, 0x14, 0x93, 0x0a, 0x88, 0x7c, 0xaa,
+	0x84, 0x1a, 0xa1, 0x72, 0xe0, 0xc0, 0x91, 0x22, 0x24, 0xa4, 0x5e, 0x1c, 0x24, 0x8e, 0x91, 0x63,
+	0x0f, 0xa9, 0x91, 0xed, 0x35, 0xbb, 0xeb, 0x92, 0x3e, 0x09, 0xe2, 0x11, 0xb8, 0xf0, 0x12, 0xbc,
+	0x18, 0xda, 0xd9, 0x75, 0x6d, 0x42, 0x52, 0xb8, 0xed, 0xf7, 0x7d, 0xe3, 0xdd, 0x99, 0x6f, 0xc6,
+	0x03, 0x93, 0x4f, 0x6d, 0xbe, 0xc6, 0x79, 0x23, 0xb8, 0xe2, 0x2c, 0x20, 0x20, 0x9a, 0x6c, 0x0a,
+	0x57, 0x5c, 0x2a, 0xc3, 0xc6, 0x1c, 0xfe, 0x5f, 0xb4, 0xab, 0xaa, 0x50, 0x09, 0x7e, 0x6e, 0x51,
+	0x2a, 0x76, 0x0c, 0xbe, 0xe4, 0xad, 0xc8, 0x30, 0x72, 0x66, 0xce, 0x69, 0x98, 0x58, 0xc4, 0x18,
+	0x8c, 0xca, 0xb4, 0x5e, 0x47, 0x2e, 0xb1, 0x74, 0x66, 0x11, 0x8c, 0x1b, 0xc1, 0x57, 0x25, 0x56,
+	0xd1, 0x01, 0xd1, 0x1d, 0xd4, 0xca, 0x35, 0x0a, 0x59, 0xf0, 0x3a, 0x1a, 0x19, 0xc5, 0xc2, 0x78,
+	0x06, 0x87, 0xdd, 0x83, 0xb2, 0xe1, 0xb5, 0x44, 0x76, 0x08, 0x6e, 0x91, 0xdb, 0xd7, 0xdc, 0x22,
+	0x8f, 0x9f, 0xc0, 0x7f, 0x1f, 0x52, 0x95, 0x5d, 0x75, 0x19, 0x6d, 0xeb, 0x31, 0xdc, 0x7b, 0x8b,
+	0xfa, 0xf3, 0xb6, 0x54, 0xfb, 0x62, 0x7e, 0x38, 0x70, 0x7f, 0x10, 0xb4, 0xfb, 0x25, 0xaa, 0x55,
+	0xa5, 0xaa, 0x95, 0xb6, 0x2a, 0x8b, 0x34, 0x4f, 0x66, 0xe5, 0x54, 0x96, 0x97, 0x58, 0xc4, 0x8e,
+	0xc0, 0x53, 0x5c, 0xa5, 0x25, 0xd5, 0xe4, 0x25, 0x06, 0x68, 0x16, 0x85, 0xe0, 0x22, 0xf2, 0xe8,
+	0x12, 0x03, 0xd8, 0x19, 0xf8, 0x82, 0x5e, 0x8f, 0xfc, 0x99, 0x73, 0x3a, 0x39, 0x7f, 0x30, 0xef,
+	0xfc, 0x9f, 0xbf, 0xd3, 0x07, 0x9b, 0x9a, 0x0d, 0x8a, 0xbf, 0xba, 0x00, 0xc4, 0xbf, 0xb9, 0xc6,
+	0x5a, 0x69, 0xb7, 0xd5, 0x4d, 0xd3, 0xf5, 0x80, 0xce, 0xc4, 0x15, 0x15, 0x52, 0xae, 0x07, 0x09,
+	0x9d, 0x89, 0x43, 0xa9, 0x6c, 0x9e, 0x74, 0x1e, 0x64, 0x3f, 0xda, 0x9d, 0xbd, 0x37, 0xcc, 0xfe,
+	0x0c, 0xfc, 0x1c, 0x55, 0x5a, 0x94, 0x7b, 0xf2, 0xbc, 0x20, 0x31, 0xb1, 0x41, 0x83, 0xb2, 0xc6,
+	0xff, 0x50, 0x56, 0xef, 0x4d, 0x30, 0xf4, 0xe6, 0x19, 0x78, 0xda, 0x69, 0x8c, 0x42, 0x7b, 0x47,
+	0x85, 0x52, 0xa6, 0x6b, 0x9c, 0x2f, 0x34, 0x7b, 0x69, 0x40, 0x62, 0x62, 0xe2, 0x6f, 0x2e, 0x4c,
+	0x06, 0x57, 0xeb, 0xd1, 0x92, 0x6d, 0x96, 0xa1, 0x94, 0xe4, 0x4e, 0x90, 0x74, 0xd0, 0x0e, 0x5d,
+	0x5e, 0x64, 0xca, 0xf6, 0xb3, 0x83, 0xec, 0x11, 0x04, 0xb8, 0xc1, 0x25, 0xd9, 0xa7, 0xad, 0x72,
+	0x93, 0x31, 0x6e, 0xf0, 0xbd, 0x76, 0xf0, 0x31, 0x80, 0x96, 0x2a, 0xac, 0xb8, 0xb8, 0x21, 0xc7,
+	0x46, 0x49, 0x88, 0x1b, 0xbc, 0x24, 0x82, 0x9d, 0x40, 0x88, 0x9b, 0x42, 0x2d, 0x33, 0x9e, 0xa3,
+	0x35, 0x2e, 0xd0, 0xc4, 0x6b, 0x9e, 0xa3, 0x16, 0x5b, 0x89, 0xb9, 0xb9, 0xd7, 0xa7, 0x4f, 0x03,
+	0x4d, 0xd0, 0xc5, 0x47, 0xe0, 0xc9, 0x8c, 0x0b, 0x24, 0xa3, 0xbc, 0xc4, 0x00, 0xfd, 0xdc, 0xc7,
+	0xb6, 0x2c, 0x97, 0x46, 0x0a, 0x48, 0x0a, 0x35, 0xb3, 0x20, 0xb9, 0xef, 0x46, 0x38, 0x3b, 0xf8,
+	0x6b, 0x37, 0xe2, 0xef, 0x9d, 0x37, 0x86, 0xd7, 0xe3, 0x50, 0xa7, 0xd5, 0xed, 0xd8, 0xd4, 0xa9,
+	0xc9, 0xa3, 0xa8, 0x9b, 0xb6, 0xf3, 0xc4, 0x00, 0x3d, 0x24, 0xbc, 0x55, 0x9a, 0x36, 0x7f, 0xae,
+	0x45, 0x9a, 0x4f, 0x6b, 0xf9, 0x05, 0x85, 0xfd, 0x6f, 0x2d, 0xd2, 0xde, 0x66, 0xbc, 0xaa, 0xb0,
+	0x56, 0x76, 0xcc, 0x3b, 0xd8, 0xd7, 0xe9, 0x0f, 0xeb, 0x1c, 0xf4, 0x62, 0xbc, 0xbf, 0x17, 0xc1,
+	0x5d, 0xbd, 0x08, 0xef, 0xec, 0x05, 0x6c, 0xf5, 0xe2, 0x29, 0x4c, 0x48, 0x94, 0xc5, 0xba, 0x4e,
+	0xcb, 0x68, 0x42, 0x32, 0x68, 0x6a, 0x41, 0xcc, 0xf9, 0x4f, 0x07, 0x3c, 0xf2, 0x8a, 0xbd, 0x02,
+	0xdf, 0xac, 0x20, 0xf6, 0xb0, 0xb7, 0xf7, 0xb7, 0x2d, 0x38, 0x8d, 0xfe, 0x14, 0xec, 0x0e, 0x79,
+	0x09, 0x1e, 0x6d, 0x27, 0x76, 0xdc, 0x87, 0x0c, 0xd7, 0xd5, 0xf4, 0x68, 0xab, 0x65, 0xf4, 0x43,
+	0x3f, 0x77, 0xd8, 0x05, 0x84, 0xb7, 0x1b, 0x89, 0x4d, 0xfb, 0xa0, 0xed, 0x5d, 0x36, 0x3d, 0xd9,
+	0xa9, 0x99, 0xe7, 0x57, 0x3e, 0xad, 0xed, 0x17, 0xbf, 0x06, 0x00, 0x65, 0x03, 0x43, 0xa3, 0xdb,
+	0x05, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// JudgeClient is the client API for Judge service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type JudgeClient interface {
+	// Submit queues a submission and returns its id immediately.
+	Submit(ctx context.Context, in *SubmitRequest, opts ...grpc.CallOption) (*SubmitResponse, error)
+	// Watch streams the events of a submission, starting from the first one,
+	// and ends after the judge_finished event.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Judge_WatchClient, error)
+	// GetResult returns the current status, and the result once finished.
+	GetResult(ctx context.Context, in *GetResultRequest, opts ...grpc.CallOption) (*GetResultResponse, error)
+}
+
+type judgeClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewJudgeClient(cc *grpc.ClientConn) JudgeClient {
+	return &judgeClient{cc}
+}
+
+func (c *judgeClient) Submit(ctx context.Context, in *SubmitRequest, opts ...grpc.CallOption) (*SubmitResponse, error) {
+	out := new(SubmitResponse)
+	err := c.cc.Invoke(ctx, "/judgerpc.Judge/Submit", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *judgeClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Judge_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Judge_serviceDesc.Streams[0], "/judgerpc.Judge/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &judgeWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Judge_WatchClient interface {
+	Recv() (*JudgeEvent, error)
+	grpc.ClientStream
+}
+
+type judgeWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *judgeWatchClient) Recv() (*JudgeEvent, error) {
+	m := new(JudgeEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *judgeClient) GetResult(ctx context.Context, in *GetResultRequest, opts ...grpc.CallOption) (*GetResultResponse, error) {
+	out := new(GetResultResponse)
+	err := c.cc.Invoke(ctx, "/judgerpc.Judge/GetResult", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// JudgeServer is the server API for Judge service.
+type JudgeServer interface {
+	// Submit queues a submission and returns its id immediately.
+	Submit(context.Context, *SubmitRequest) (*SubmitResponse, error)
+	// Watch streams the events of a submission, starting from the first one,
+	// and ends after the judge_finished event.
+	Watch(*WatchRequest, Judge_WatchServer) error
+	// GetResult returns the current status, and the result once finished.
+	GetResult(context.Context, *GetResultRequest) (*GetResultResponse, error)
+}
+
+func RegisterJudgeServer(s *grpc.Server, srv JudgeServer) {
+	s.RegisterService(&_Judge_serviceDesc, srv)
+}
+
+func _Judge_Submit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JudgeServer).Submit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/judgerpc.Judge/Submit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JudgeServer).Submit(ctx, req.(*SubmitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Judge_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(JudgeServer).Watch(m, &judgeWatchServer{stream})
+}
+
+type Judge_WatchServer interface {
+	Send(*JudgeEvent) error
+	grpc.ServerStream
+}
+
+type judgeWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *judgeWatchServer) Send(m *JudgeEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Judge_GetResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetResultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JudgeServer).GetResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/judgerpc.Judge/GetResult",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JudgeServer).GetResult(ctx, req.(*GetResultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Judge_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "judgerpc.Judge",
+	HandlerType: (*JudgeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Submit",
+			Handler:    _Judge_Submit_Handler,
+		},
+		{
+			MethodName: "GetResult",
+			Handler:    _Judge_GetResult_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Judge_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "judge.proto",
+}
@@ -0,0 +1,74 @@
+package judgerpc
+
+import (
+	"context"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/daemon"
+	"github.com/erjiaqing/PCIJudger2/pkg/pci15"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements JudgeServer on top of a daemon.Pool, which queues the
+// submissions and runs pci15.Judge on them.
+type Server struct {
+	pool *daemon.Pool
+}
+
+func NewServer(pool *daemon.Pool) *Server {
+	return &Server{pool: pool}
+}
+
+func (s *Server) Submit(ctx context.Context, req *SubmitRequest) (*SubmitResponse, error) {
+	rec, err := s.pool.Submit(&daemon.Submission{
+		Source:   req.Source,
+		Language: req.Lang,
+		Problem:  req.Problem,
+		Version:  req.Version,
+	})
+	switch err {
+	case nil:
+		return &SubmitResponse{Id: rec.ID}, nil
+	case daemon.ErrQueueFull, daemon.ErrDraining:
+		return nil, status.Error(codes.Unavailable, err.Error())
+	default:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+}
+
+func (s *Server) Watch(req *WatchRequest, stream Judge_WatchServer) error {
+	events, err := s.pool.Watch(stream.Context(), req.Id)
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	var st pci15.HostStatus
+	seq := uint64(0)
+	for ev := range events {
+		pev := EventToProto(ev)
+		if st.Apply(ev) {
+			seq++
+			pev.State = st.Message()
+			pev.State.Uid = req.Id
+			pev.State.Seq = seq
+		}
+		if err := stream.Send(pev); err != nil {
+			return err
+		}
+	}
+	return stream.Context().Err()
+}
+
+func (s *Server) GetResult(ctx context.Context, req *GetResultRequest) (*GetResultResponse, error) {
+	rec, ok := s.pool.Get(req.Id)
+	if !ok {
+		return nil, status.Error(codes.NotFound, daemon.ErrNotFound.Error())
+	}
+	return &GetResultResponse{
+		Id:     rec.ID,
+		Status: string(rec.Status),
+		Judged: int32(rec.Judged),
+		Total:  int32(rec.Total),
+		Error:  rec.Error,
+		Result: ResultToProto(rec.Result),
+	}, nil
+}
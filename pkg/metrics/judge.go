@@ -0,0 +1,21 @@
+package metrics
+
+// Metrics of the judge, all registered in Default.
+var (
+	Judgements = Default.NewCounter("pci_judgements_total",
+		"Finished judgements by verdict and language.", "verdict", "lang")
+	CompileSeconds = Default.NewHistogram("pci_compile_seconds",
+		"Wall time of compilations.", []float64{0.5, 1, 2, 5, 10, 20, 30}, "lang")
+	TestCPUSeconds = Default.NewHistogram("pci_test_cpu_seconds",
+		"CPU time of a submission on one test case.", []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10}, "lang")
+	SystemErrors = Default.NewCounter("pci_system_errors_total",
+		"SE verdicts and failed judgements by cause.", "cause")
+	Executions = Default.NewCounter("pci_executions_total",
+		"Sandboxed executions by the limit they exceeded, none if they did not.", "exceeded")
+	MirrorFSSeconds = Default.NewHistogram("pci_mirrorfs_seconds",
+		"Latency of mirrorfs setup and teardown.", []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5}, "op")
+	MirrorFSFailures = Default.NewCounter("pci_mirrorfs_failures_total",
+		"Failed mirrorfs setups and teardowns.", "op")
+	CGroupCleanupFailures = Default.NewCounter("pci_cgroup_cleanup_failures_total",
+		"Failures to clean up a cgroup.")
+)
@@ -0,0 +1,242 @@
+// Package metrics is a small registry of counters and histograms, exported
+// in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type metric interface {
+	write(w io.Writer)
+}
+
+type Registry struct {
+	mut     sync.Mutex
+	names   []string
+	metrics map[string]metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]metric),
+	}
+}
+
+// Default is the registry the judge reports to.
+var Default = NewRegistry()
+
+func (r *Registry) register(name string, m metric) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metric %s registered twice", name))
+	}
+	r.names = append(r.names, name)
+	sort.Strings(r.names)
+	r.metrics[name] = m
+}
+
+// WriteText writes all metrics in the text exposition format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mut.Lock()
+	names := append([]string{}, r.names...)
+	r.mut.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		r.mut.Lock()
+		m := r.metrics[name]
+		r.mut.Unlock()
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// WriteFile dumps all metrics to path, replacing it atomically.
+func (r *Registry) WriteFile(path string) error {
+	fp, err := ioutil.TempFile(filepath.Dir(path), ".metrics")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fp.Name())
+	if err := r.WriteText(fp); err != nil {
+		fp.Close()
+		return err
+	}
+	if err := fp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(fp.Name(), path)
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.WriteText(w)
+	})
+}
+
+// labelSet is the values of the labels of one series, joined into a map key.
+type labelSet struct {
+	names []string
+}
+
+func (l labelSet) key(values []string) string {
+	if len(values) != len(l.names) {
+		panic(fmt.Sprintf("expected %d label values, got %d", len(l.names), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func (l labelSet) format(key string, extra ...string) string {
+	pairs := make([]string, 0, len(l.names)+1)
+	if len(l.names) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", l.names[i], escape(v)))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extra[i], escape(extra[i+1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var escaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+func escape(v string) string {
+	return escaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type Counter struct {
+	name   string
+	help   string
+	labels labelSet
+	mut    sync.Mutex
+	values map[string]float64
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		name:   name,
+		help:   help,
+		labels: labelSet{names: labels},
+		values: make(map[string]float64),
+	}
+	r.register(name, c)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	key := c.labels.key(labelValues)
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.values[key] += v
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	keys := make(map[string]bool)
+	for k := range c.values {
+		keys[k] = true
+	}
+	for _, k := range sortedKeys(keys) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labels.format(k), formatFloat(c.values[k]))
+	}
+}
+
+type histogramValue struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type Histogram struct {
+	name    string
+	help    string
+	labels  labelSet
+	buckets []float64
+	mut     sync.Mutex
+	values  map[string]*histogramValue
+}
+
+// NewHistogram registers a histogram with the given upper bounds of buckets,
+// in increasing order, the +Inf bucket is implicit.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		labels:  labelSet{names: labels},
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+	}
+	r.register(name, h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.labels.key(labelValues)
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	val, ok := h.values[key]
+	if !ok {
+		val = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = val
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			val.counts[i]++
+		}
+	}
+	val.sum += v
+	val.count++
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make(map[string]bool)
+	for k := range h.values {
+		keys[k] = true
+	}
+	for _, k := range sortedKeys(keys) {
+		val := h.values[k]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels.format(k, "le", formatFloat(bound)), val.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels.format(k, "le", "+Inf"), val.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labels.format(k), formatFloat(val.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labels.format(k), val.count)
+	}
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	tests := []struct {
+		name   string
+		record func(r *Registry)
+		want   string
+	}{
+		{
+			name: "counter",
+			record: func(r *Registry) {
+				c := r.NewCounter("judge_total", "Judgements.", "verdict")
+				c.Inc("WA")
+				c.Inc("AC")
+				c.Add(2, "AC")
+			},
+			want: "# HELP judge_total Judgements.\n# TYPE judge_total counter\n" +
+				"judge_total{verdict=\"AC\"} 3\n" +
+				"judge_total{verdict=\"WA\"} 1\n",
+		},
+		{
+			name: "counter without labels",
+			record: func(r *Registry) {
+				r.NewCounter("errors_total", "Errors.").Add(0.5)
+			},
+			want: "# HELP errors_total Errors.\n# TYPE errors_total counter\nerrors_total 0.5\n",
+		},
+		{
+			name: "escaped label",
+			record: func(r *Registry) {
+				r.NewCounter("lang_total", "Languages.", "language").Inc("c\"pp\\\n")
+			},
+			want: "# HELP lang_total Languages.\n# TYPE lang_total counter\n" +
+				"lang_total{language=\"c\\\"pp\\\\\\n\"} 1\n",
+		},
+		{
+			name: "histogram",
+			record: func(r *Registry) {
+				h := r.NewHistogram("run_seconds", "Runs.", []float64{0.1, 1}, "op")
+				h.Observe(0.05, "setup")
+				h.Observe(0.5, "setup")
+				h.Observe(2, "setup")
+			},
+			want: "# HELP run_seconds Runs.\n# TYPE run_seconds histogram\n" +
+				"run_seconds_bucket{op=\"setup\",le=\"0.1\"} 1\n" +
+				"run_seconds_bucket{op=\"setup\",le=\"1\"} 2\n" +
+				"run_seconds_bucket{op=\"setup\",le=\"+Inf\"} 3\n" +
+				"run_seconds_sum{op=\"setup\"} 2.55\n" +
+				"run_seconds_count{op=\"setup\"} 3\n",
+		},
+		{
+			name: "sorted by name",
+			record: func(r *Registry) {
+				r.NewCounter("b_total", "B.").Inc()
+				r.NewCounter("a_total", "A.").Inc()
+			},
+			want: "# HELP a_total A.\n# TYPE a_total counter\na_total 1\n" +
+				"# HELP b_total B.\n# TYPE b_total counter\nb_total 1\n",
+		},
+	}
+	for _, tt := range tests {
+		r := NewRegistry()
+		tt.record(r)
+		var buf bytes.Buffer
+		if err := r.WriteText(&buf); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, buf.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package pci15
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultArchiveMaxSize     = 16 << 20
+	defaultArchiveMaxFiles    = 1000
+	defaultArchiveMaxUnpacked = 64 << 20
+)
+
+// Project is how a language builds a submitted archive. Entry is the file,
+// relative to the root of the archive, telling the project is of this kind,
+// like a Makefile. An empty Cmd means there is nothing to build.
+type Project struct {
+	Entry      string   `json:"entry"`
+	Cmd        []string `json:"args"`
+	Executable string   `json:"executable"`
+	Execute    []string `json:"cmd"`
+	TimeLimit  float32  `json:"timelimit"` // compile time limit of the language if 0
+}
+
+// project returns the first project of l whose entry is in dir.
+func (l *Language) project(dir string) *Project {
+	for _, p := range l.Project {
+		if st, err := os.Stat(filepath.Join(dir, p.Entry)); err == nil && st.Mode().IsRegular() {
+			return p
+		}
+	}
+	return nil
+}
+
+// archiveFormat tells the format of the archive at path by its first bytes:
+// zip, tar or tgz, or nothing when it is not an archive.
+func archiveFormat(path string) string {
+	fp, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer fp.Close()
+	head := make([]byte, 262)
+	n, _ := io.ReadFull(fp, head)
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return "zip"
+	case bytes.HasPrefix(head, []byte("\x1f\x8b")):
+		return "tgz"
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return "tar"
+	}
+	return ""
+}
+
+// archiveLimits are the limits of unpacking, checked on the content actually
+// written and not on what the archive declares. Directories count as files.
+type archiveLimits struct {
+	files    int
+	unpacked int64
+}
+
+func (conf *Config) archiveLimits() (int64, *archiveLimits) {
+	size, limits := conf.ArchiveMaxSize, &archiveLimits{conf.ArchiveMaxFiles, conf.ArchiveMaxUnpacked}
+	if size <= 0 {
+		size = defaultArchiveMaxSize
+	}
+	if limits.files <= 0 {
+		limits.files = defaultArchiveMaxFiles
+	}
+	if limits.unpacked <= 0 {
+		limits.unpacked = defaultArchiveMaxUnpacked
+	}
+	return size, limits
+}
+
+// archivePath checks name, the path of an entry of an archive, stays inside
+// the archive, and returns it cleaned. It is empty for the root.
+func archivePath(name string) (string, error) {
+	name = strings.Replace(name, "\\", "/", -1)
+	if strings.HasPrefix(name, "/") || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%s: absolute path in archive", name)
+	}
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%s: path leaves the archive", name)
+	}
+	if clean == "." {
+		return "", nil
+	}
+	return clean, nil
+}
+
+// unpackFile writes the content of r to name in dir, counted against the
+// unpacked size of limits.
+func unpackFile(dir, name string, r io.Reader, limits *archiveLimits) error {
+	dst := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+		return err
+	}
+	fp, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0755)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	defer fp.Close()
+	n, err := io.CopyN(fp, r, limits.unpacked+1)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if limits.unpacked -= n; limits.unpacked < 0 {
+		return errors.New("archive too large once unpacked")
+	}
+	return nil
+}
+
+// unpackArchive unpacks the zip or tar archive at path into dir, and returns
+// the files unpacked, relative to dir. Links and special files are refused,
+// so are paths leaving dir.
+func unpackArchive(conf *Config, path, dir string) ([]string, error) {
+	maxSize, limits := conf.archiveLimits()
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if st.Size() > maxSize {
+		return nil, fmt.Errorf("archive is %d bytes, at most %d are allowed", st.Size(), maxSize)
+	}
+
+	var files []string
+	add := func(name string, isDir bool, r io.Reader) error {
+		name, err := archivePath(name)
+		if err != nil || name == "" {
+			return err
+		}
+		if limits.files--; limits.files < 0 {
+			return errors.New("too many files in archive")
+		}
+		if isDir {
+			return os.MkdirAll(filepath.Join(dir, filepath.FromSlash(name)), 0777)
+		}
+		if err := unpackFile(dir, name, r, limits); err != nil {
+			return err
+		}
+		files = append(files, name)
+		return nil
+	}
+
+	switch archiveFormat(path) {
+	case "zip":
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, fmt.Errorf("bad zip archive: %v", err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			mode := f.Mode()
+			if !mode.IsDir() && !mode.IsRegular() {
+				return nil, fmt.Errorf("%s: only files and directories are allowed in archive", f.Name)
+			}
+			if err := func() error {
+				r, err := f.Open()
+				if err != nil {
+					return fmt.Errorf("%s: %v", f.Name, err)
+				}
+				defer r.Close()
+				return add(f.Name, mode.IsDir(), r)
+			}(); err != nil {
+				return nil, err
+			}
+		}
+	case "tar", "tgz":
+		fp, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+		var r io.Reader = fp
+		if archiveFormat(path) == "tgz" {
+			gz, err := gzip.NewReader(fp)
+			if err != nil {
+				return nil, fmt.Errorf("bad gzip archive: %v", err)
+			}
+			defer gz.Close()
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("bad tar archive: %v", err)
+			}
+			switch hdr.Typeflag {
+			case tar.TypeDir:
+				err = add(hdr.Name, true, nil)
+			case tar.TypeReg, tar.TypeRegA:
+				err = add(hdr.Name, false, tr)
+			case tar.TypeXGlobalHeader:
+			default:
+				err = fmt.Errorf("%s: only files and directories are allowed in archive", hdr.Name)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, errors.New("not a zip or tar archive")
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// GetProjectCommand returns how the project unpacked in dir is built and run,
+// by the first project of the language whose entry is in dir.
+func GetProjectCommand(code *SourceCode, conf *Config, dir string) (*ExecuteCommand, *Language, error) {
+	language := &Language{}
+	if err := loadYAML(filepath.Join(conf.LanguageStorage, code.Language+".yaml"), language); err != nil {
+		return nil, nil, err
+	}
+	if len(language.Project) == 0 {
+		return nil, nil, fmt.Errorf("%s does not support archives", code.Language)
+	}
+	project := language.project(dir)
+	if project == nil {
+		entries := make([]string, 0, len(language.Project))
+		for _, p := range language.Project {
+			entries = append(entries, p.Entry)
+		}
+		return nil, nil, fmt.Errorf("archive has none of %s at its root", strings.Join(entries, ", "))
+	}
+
+	ret := &ExecuteCommand{Source: filepath.Join(dir, project.Entry)}
+	entryContent, _ := ReadFile(ret.Source)
+	Variables := language.variables(entryContent)
+	Variables["entry"] = ret.Source
+	Variables["source"] = ret.Source
+	entryWOsuffix := strings.LastIndex(ret.Source, ".")
+	if entryWOsuffix < len(dir) {
+		entryWOsuffix = len(ret.Source)
+	}
+	Variables["entry<"] = ret.Source[:entryWOsuffix]
+	Variables["source<"] = Variables["entry<"]
+	for k, v := range code.Variables {
+		Variables[k] = v
+	}
+
+	ret.Executable = project.Executable
+	for k, v := range Variables {
+		ret.Executable = strings.Replace(ret.Executable, "{"+k+"}", v, 1000000)
+	}
+	if !filepath.IsAbs(ret.Executable) {
+		ret.Executable = filepath.Join(dir, ret.Executable)
+	}
+	Variables["executable"] = ret.Executable
+	for _, str := range project.Cmd {
+		for k, v := range Variables {
+			str = strings.Replace(str, "{"+k+"}", v, 1000000)
+		}
+		ret.Compile = append(ret.Compile, str)
+	}
+	for _, str := range project.Execute {
+		for k, v := range Variables {
+			str = strings.Replace(str, "{"+k+"}", v, 1000000)
+		}
+		ret.Execute = append(ret.Execute, str)
+	}
+	return ret, language, nil
+}
+
+// prepareProject unpacks the archive submitted as code into workDir, and
+// finds how its language builds it. The returned code is never nil, an error
+// is why the archive can not be compiled.
+func prepareProject(conf *Config, code *SourceCode, problemConf *ProblemConfig, workDir string) (*ExecuteCommand, *Language, *SourceCode, []string, error) {
+	newCode := &SourceCode{
+		Source:   code.Source,
+		Language: code.Language,
+		Project:  true,
+	}
+	if problemConf.Template != "" || len(problemConf.Grader) > 0 {
+		return nil, nil, newCode, nil, errors.New("the problem does not accept archives")
+	}
+	files, err := unpackArchive(conf, code.Source, workDir)
+	if err != nil {
+		return nil, nil, newCode, nil, err
+	}
+	execCommand, language, err := GetProjectCommand(newCode, conf, workDir)
+	if err != nil {
+		return nil, nil, newCode, files, err
+	}
+	newCode.Source = execCommand.Source
+	return execCommand, language, newCode, files, nil
+}
@@ -0,0 +1,170 @@
+package pci15
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArchivePath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"main.c", "main.c", true},
+		{"src/./lib/../main.c", "src/main.c", true},
+		{"src\\main.c", "src/main.c", true},
+		{"src/", "src", true},
+		{".", "", true},
+		{"./", "", true},
+		{"/etc/passwd", "", false},
+		{"\\etc\\passwd", "", false},
+		{"..", "", false},
+		{"../main.c", "", false},
+		{"src/../../main.c", "", false},
+		{"src\\..\\..\\main.c", "", false},
+	}
+	for _, tt := range tests {
+		got, err := archivePath(tt.name)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("%s: got %q, %v, want %q, ok %v", tt.name, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+// archiveEntry is an entry of a test archive: a directory when its name ends
+// with a slash, a symlink to link when it is set, otherwise a file.
+type archiveEntry struct {
+	name, content, link string
+}
+
+func writeZip(path string, entries []archiveEntry) error {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		content := e.content
+		switch {
+		case e.link != "":
+			hdr.SetMode(os.ModeSymlink | 0777)
+			content = e.link
+		case strings.HasSuffix(e.name, "/"):
+			hdr.SetMode(os.ModeDir | 0755)
+		default:
+			hdr.SetMode(0644)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func writeTar(path string, entries []archiveEntry) error {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.content)), Format: tar.FormatUSTAR}
+		switch {
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		case strings.HasSuffix(e.name, "/"):
+			hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0755, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.content)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func TestUnpackArchive(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	project := []archiveEntry{{name: "src/"}, {name: "src/main.c", content: "int main;"}, {name: "Makefile", content: "all:"}}
+	tests := []struct {
+		name    string
+		conf    *Config
+		entries []archiveEntry
+		want    []string // nil when unpacking fails
+	}{
+		{"project", &Config{}, project, []string{"Makefile", "src/main.c"}},
+		{"files", &Config{ArchiveMaxFiles: 3}, project, []string{"Makefile", "src/main.c"}},
+		{"directories count as files", &Config{ArchiveMaxFiles: 2}, project, nil},
+		{"too many directories", &Config{ArchiveMaxFiles: 2}, []archiveEntry{{name: "a/"}, {name: "b/"}, {name: "c/"}}, nil},
+		{"unpacked", &Config{ArchiveMaxUnpacked: 13}, project, []string{"Makefile", "src/main.c"}},
+		{"too large unpacked", &Config{ArchiveMaxUnpacked: 12}, project, nil},
+		{"too large", &Config{ArchiveMaxSize: 100}, project, nil},
+		{"root", &Config{}, []archiveEntry{{name: "./"}, {name: "./main.c", content: "x"}}, []string{"main.c"}},
+		{"leaves the archive", &Config{}, []archiveEntry{{name: "../main.c", content: "x"}}, nil},
+		{"absolute", &Config{}, []archiveEntry{{name: "/main.c", content: "x"}}, nil},
+		{"symlink", &Config{}, []archiveEntry{{name: "passwd", link: "/etc/passwd"}}, nil},
+		{"duplicate", &Config{}, []archiveEntry{{name: "main.c", content: "x"}, {name: "main.c", content: "y"}}, nil},
+	}
+	for _, tt := range tests {
+		for format, write := range map[string]func(string, []archiveEntry) error{"zip": writeZip, "tar": writeTar} {
+			name := tt.name + " " + format
+			path := filepath.Join(tmp, strings.Replace(name, " ", "_", -1)+"."+format)
+			if err := write(path, tt.entries); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			dir := strings.TrimSuffix(path, "."+format)
+			if err := os.Mkdir(dir, 0777); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			files, err := unpackArchive(tt.conf, path, dir)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("%s: unpacked %v", name, files)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			if !reflect.DeepEqual(files, tt.want) {
+				t.Errorf("%s: got %v, want %v", name, files, tt.want)
+			}
+			for _, f := range tt.want {
+				if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f))); err != nil {
+					t.Errorf("%s: %v", name, err)
+				}
+			}
+		}
+	}
+
+	path := filepath.Join(tmp, "main.c")
+	if err := ioutil.WriteFile(path, []byte("int main;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if files, err := unpackArchive(&Config{}, path, tmp); err == nil {
+		t.Errorf("not an archive: unpacked %v", files)
+	}
+}
@@ -1,78 +1,90 @@
 package pci15
 
 import (
-	"os"
+	"context"
+	"path/filepath"
 )
 
 type CheckResult struct {
 	Success      bool         `json:"success"`
 	Build        *BuildResult `json:"build"`
 	RunSolutions []*CheckRun  `json:"sols"`
+
+	Coverage  *Coverage            `json:"coverage,omitempty"`
+	TimeLimit *TimeLimitSuggestion `json:"timelimit,omitempty"`
 }
 
 type CheckRun struct {
 	*JudgeResult
-	Expected []string `json:"expected"`
+	Source     string       `json:"source"`
+	Expected   []string     `json:"expected"`
+	Violations []*Violation `json:"violations,omitempty"` // expectations not met
 }
 
-func CheckProblemRepo(conf *Config, source string) (res *CheckResult, err error) {
-	res = &CheckResult{}
-	res.Success = true
+// checkConf is the config test solutions of the problem in source are judged
+// with, running every test and hiding nothing.
+func checkConf(conf *Config, source string) *Config {
+	return &Config{
+		Tmp:             conf.Tmp,
+		IsDocker:        conf.IsDocker,
+		Problem:         source,
+		LanguageStorage: conf.LanguageStorage,
+		ProblemPath:     source,
+		SupportFiles:    conf.SupportFiles,
+		MirrorFSConfig:  conf.MirrorFSConfig,
+		MaxJudgeThread:  conf.MaxJudgeThread,
+		RunAll:          true,
+		Feedback:        FeedbackFull,
+	}
+}
 
-	var oldCwd string
-	if oldCwd, err = os.Getwd(); err != nil {
-		return res, err
+// solution returns the source code of s, relative to the problem in source.
+func (s *TestSolution) solution(source string) SourceCode {
+	solution := s.SourceCode
+	if !filepath.IsAbs(solution.Source) {
+		solution.Source = filepath.Join(source, solution.Source)
 	}
+	return solution
+}
 
-	defer os.Chdir(oldCwd)
+func CheckProblemRepo(ctx context.Context, conf *Config, source string) (res *CheckResult, err error) {
+	res = &CheckResult{}
+	res.Success = true
 
-	if res.Build, err = BuildProblem(source, "", conf); err != nil {
+	if res.Build, err = BuildProblem(ctx, source, "", conf); err != nil {
+		res.Success = false
 		return res, err
 	}
 
-	if err = os.Chdir(source); err != nil {
-		return nil, err
-	}
-
 	problemMeta := &ProblemConfig{}
-	if err := loadYAML("problem.yaml", problemMeta); err != nil {
+	if err := loadYAML(filepath.Join(source, "problem.yaml"), problemMeta); err != nil {
 		// problem.yaml should be valid and well-formatted yaml file
 		return nil, err
 	}
 
+	var runs []*JudgeResult
 	for _, r := range problemMeta.TestSolutions {
-		judgerConf := &Config{
-			Tmp:             conf.Tmp,
-			IsDocker:        conf.IsDocker,
-			Problem:         source,
-			LanguageStorage: conf.LanguageStorage,
-			ProblemPath:     source,
-			SupportFiles:    conf.SupportFiles,
-			MirrorFSConfig:  conf.MirrorFSConfig,
-			MaxJudgeThread:  conf.MaxJudgeThread,
-			RunAll:          true,
-		}
-		runRes, err := Judge(judgerConf, &r.SourceCode, judgerConf.Problem)
+		judgerConf := checkConf(conf, source)
+		solution := r.solution(source)
+		runRes, err := Judge(ctx, judgerConf, &solution, judgerConf.Problem)
 		if err != nil {
 			return nil, err
 		}
 
-		for _, r2 := range runRes.Detail {
-			findValid := false
-			for _, v := range r.ExpectedVerdict {
-				// No IG should found
-				if v == r2.Verdict {
-					findValid = true
-					break
-				}
-			}
-			if !findValid {
-				res.Success = false
-				runRes.Success = false
-			}
+		violations := r.violations(runRes, problemMeta.Case)
+		if len(violations) > 0 {
+			res.Success = false
+			runRes.Success = false
 		}
-		res.RunSolutions = append(res.RunSolutions, &CheckRun{runRes, append([]string{}, r.ExpectedVerdict...)})
+		res.RunSolutions = append(res.RunSolutions, &CheckRun{
+			JudgeResult: runRes,
+			Source:      r.Source,
+			Expected:    append([]string{}, r.ExpectedVerdict...),
+			Violations:  violations,
+		})
+		runs = append(runs, runRes)
 	}
+	res.Coverage = analyzeCoverage(problemMeta.Case, problemMeta.TestSolutions, runs)
 
 	return res, nil
 }
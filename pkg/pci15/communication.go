@@ -0,0 +1,453 @@
+package pci15
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// ProblemTypeCommunication problems run the program several times on every
+// test, as their Communication describes.
+const ProblemTypeCommunication = "communication"
+
+// Communication is the run graph of a communication problem: the instances
+// of the program run on every test, the manager some of them talk with, and
+// which outputs feed which inputs.
+type Communication struct {
+	// Manager talks with the runs connected to it, through FIFOs given as
+	// arguments after those of an interactor: input, output, answer, then
+	// the FIFO to and from every connected run, in order
+	Manager *SourceCode         `json:"manager,omitempty"`
+	Runs    []*CommunicationRun `json:"runs"`
+	// Output is the run whose output is checked, manager for the output of
+	// the manager. The manager if there is one, otherwise the last run.
+	Output string `json:"output,omitempty"`
+}
+
+// CommunicationRun is an instance of the program, run with Args.
+type CommunicationRun struct {
+	Name string   `json:"name"`
+	Args []string `json:"args,omitempty"`
+	// Stdin is input for the input of the test, the name of an earlier run
+	// or manager to read its output, or nothing
+	Stdin string `json:"stdin,omitempty"`
+	// Manager connects the stdin and stdout of the run to the manager. The
+	// runs connected are run together with the manager, when the first of
+	// them is reached.
+	Manager     bool   `json:"manager,omitempty"`
+	TimeLimit   uint64 `json:"timelimit,omitempty"`   // ms, the one of the problem if 0
+	MemoryLimit uint64 `json:"memorylimit,omitempty"` // MiB, the one of the problem if 0
+}
+
+const (
+	commInput   = "input"
+	commManager = "manager"
+)
+
+func (c *Communication) output() string {
+	if c.Output != "" {
+		return c.Output
+	}
+	if c.Manager != nil {
+		return commManager
+	}
+	return c.Runs[len(c.Runs)-1].Name
+}
+
+// validate checks every input of the runs is known when they start.
+func (c *Communication) validate() error {
+	if len(c.Runs) == 0 {
+		return errors.New("communication has no runs")
+	}
+	seen := make(map[string]*CommunicationRun)
+	managed, managerRan := 0, false
+	for i, run := range c.Runs {
+		if run.Name == "" {
+			return fmt.Errorf("run %d has no name", i+1)
+		}
+		if run.Name == commInput || run.Name == commManager {
+			return fmt.Errorf("run %d can not be named %s", i+1, run.Name)
+		}
+		if _, ok := seen[run.Name]; ok {
+			return fmt.Errorf("run %s appears more than once", run.Name)
+		}
+		if run.Manager {
+			if c.Manager == nil {
+				return fmt.Errorf("run %s is connected to the manager, but there is none", run.Name)
+			}
+			if run.Stdin != "" {
+				return fmt.Errorf("run %s is connected to the manager, it can not read %s", run.Name, run.Stdin)
+			}
+			managed++
+			managerRan = true
+		}
+		switch run.Stdin {
+		case "", commInput:
+		case commManager:
+			if !managerRan {
+				return fmt.Errorf("run %s reads the output of the manager before it runs", run.Name)
+			}
+		default:
+			from, ok := seen[run.Stdin]
+			if !ok {
+				return fmt.Errorf("run %s reads %s, which is not an earlier run", run.Name, run.Stdin)
+			}
+			if from.Manager {
+				return fmt.Errorf("run %s reads %s, whose output goes to the manager", run.Name, run.Stdin)
+			}
+		}
+		seen[run.Name] = run
+	}
+	if c.Manager != nil && managed == 0 {
+		return errors.New("no run is connected to the manager")
+	}
+	switch output := c.output(); output {
+	case commManager:
+		if c.Manager == nil {
+			return errors.New("the output of the manager is checked, but there is none")
+		}
+	default:
+		run, ok := seen[output]
+		if !ok {
+			return fmt.Errorf("the output of %s is checked, which is not a run", output)
+		}
+		if run.Manager {
+			return fmt.Errorf("the output of %s is checked, which goes to the manager", output)
+		}
+	}
+	return nil
+}
+
+// checkCommunication checks the problem has a valid run graph if it is a
+// communication problem, and none otherwise.
+func (p *ProblemConfig) checkCommunication() error {
+	if p.Type != ProblemTypeCommunication {
+		if p.Communication != nil {
+			return fmt.Errorf("communication is given but the problem is not of type %s", ProblemTypeCommunication)
+		}
+		return nil
+	}
+	if p.Communication == nil {
+		return errors.New("communication problems need a communication")
+	}
+	if p.Interactor != nil {
+		return errors.New("communication problems can not have an interactor, use a manager")
+	}
+	if err := p.Communication.validate(); err != nil {
+		return fmt.Errorf("bad communication: %v", err)
+	}
+	return nil
+}
+
+func commFile(workdir, uid, name, suffix string) string {
+	return filepath.Join(workdir, uid+"."+name+suffix)
+}
+
+// outputs are the files the runs of a test leave, but the output checked.
+func (c *Communication) outputs(workdir, uid string) []testOutput {
+	var ret []testOutput
+	for _, run := range c.Runs {
+		if !run.Manager && run.Name != c.output() {
+			ret = append(ret, testOutput{run.Name + "_stdout", commFile(workdir, uid, run.Name, ".stdout")})
+		}
+		ret = append(ret, testOutput{run.Name + "_stderr", commFile(workdir, uid, run.Name, ".stderr")})
+	}
+	if c.Manager != nil {
+		if c.output() != commManager {
+			ret = append(ret, testOutput{"manager_output", commFile(workdir, uid, commManager, ".out")})
+		}
+		ret = append(ret, testOutput{"manager_stderr", commFile(workdir, uid, commManager, ".stderr")})
+	}
+	return ret
+}
+
+func (r *CommunicationRun) limits(timeLimit float32, memoryLimit uint64) (float32, uint64) {
+	if r.TimeLimit != 0 {
+		timeLimit = float32(r.TimeLimit) / 1000.
+	}
+	if r.MemoryLimit != 0 {
+		memoryLimit = r.MemoryLimit
+	}
+	return timeLimit, memoryLimit * 1024 * 1024
+}
+
+func failedRun(res *ExecuteResult) bool {
+	return res.ExitReason != "none" || res.ExitCode != 0 || res.ExitSignal != 0 || res.TermSignal != 0
+}
+
+// communication runs a test of a communication problem.
+type communication struct {
+	*Communication
+	cmd         []string // of the program
+	managerCmd  []string
+	timeLimit   float32
+	memoryLimit uint64 // MiB
+	timeRatio   float32
+	chroot      string
+	workdir     string
+	uid         string
+	answer      string
+	files       map[string]string // outputs of the runs and the manager by name
+}
+
+// communicate runs the program on the test with input and answer as c
+// describes, the output checked being written to output. The result is that
+// of the first run failing, or of the manager as WA when it rejects the runs,
+// with the most time and memory of all runs, and a comment telling which
+// failed.
+func (c *Communication) communicate(ctx context.Context, log *PCILog, cmd, managerCmd []string, timeLimit float32, memoryLimit uint64, timeRatio float32, chroot, workdir, uid, input, answer, output string) (*ExecuteResult, string, error) {
+	t := &communication{
+		Communication: c,
+		cmd:           cmd,
+		managerCmd:    managerCmd,
+		timeLimit:     timeLimit,
+		memoryLimit:   memoryLimit,
+		timeRatio:     timeRatio,
+		chroot:        chroot,
+		workdir:       workdir,
+		uid:           uid,
+		answer:        answer,
+		files:         map[string]string{commInput: input},
+	}
+	for _, run := range c.Runs {
+		if !run.Manager {
+			t.files[run.Name] = commFile(workdir, uid, run.Name, ".stdout")
+		}
+	}
+	if c.Manager != nil {
+		t.files[commManager] = commFile(workdir, uid, commManager, ".out")
+	}
+	t.files[c.output()] = output
+
+	ret := &ExecuteResult{ExitReason: "none"}
+	var failure *ExecuteResult
+	comment := ""
+	took := func(name string, res *ExecuteResult) {
+		log.Entry(LevelInfo, "Run finished", LogFields{
+			"run":      name,
+			"cputime":  res.CPUTime,
+			"realtime": res.RealTime,
+			"memory":   res.ExeMemory,
+			"exitcode": res.ExitCode,
+			"exceeded": res.ExitReason,
+		})
+		if res.CPUTime > ret.CPUTime {
+			ret.CPUTime = res.CPUTime
+		}
+		if res.RealTime > ret.RealTime {
+			ret.RealTime = res.RealTime
+		}
+		if res.ExeMemory > ret.ExeMemory {
+			ret.ExeMemory = res.ExeMemory
+		}
+	}
+
+	managed := false
+	for _, run := range c.Runs {
+		if failure != nil {
+			break
+		}
+		if !run.Manager {
+			res, err := t.run(ctx, run)
+			if err != nil {
+				return nil, "", fmt.Errorf("run %s: %v", run.Name, err)
+			}
+			took(run.Name, res)
+			if failedRun(res) {
+				failure, comment = res, fmt.Sprintf("run %s: %s", run.Name, res.ExitReason)
+			}
+			continue
+		}
+		if managed {
+			continue
+		}
+		managed = true
+		results, managerRes, err := t.session(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, r := range c.Runs {
+			res, ok := results[r.Name]
+			if !ok {
+				continue
+			}
+			took(r.Name, res)
+			if failure == nil && failedRun(res) {
+				failure, comment = res, fmt.Sprintf("run %s: %s", r.Name, res.ExitReason)
+			}
+		}
+		log.Entry(LevelDebug, "Manager finished", LogFields{
+			"cputime":  managerRes.CPUTime,
+			"exitcode": managerRes.ExitCode,
+			"exceeded": managerRes.ExitReason,
+		})
+		if failure == nil && failedRun(managerRes) {
+			stderr, _ := ReadFirstBytes(commFile(workdir, uid, commManager, ".stderr"), 128)
+			failure, comment = &ExecuteResult{ExitReason: "WA"}, withComment("manager rejected the runs", stderr)
+		}
+	}
+
+	if failure != nil {
+		ret.ExitReason = failure.ExitReason
+		ret.ExitCode = failure.ExitCode
+		ret.ExitSignal = failure.ExitSignal
+		ret.TermSignal = failure.TermSignal
+	}
+	return ret, comment, nil
+}
+
+// run runs a run not connected to the manager.
+func (t *communication) run(ctx context.Context, run *CommunicationRun) (*ExecuteResult, error) {
+	stdin := os.DevNull
+	if run.Stdin != "" {
+		stdin = t.files[run.Stdin]
+	}
+	timeLimit, memoryLimit := run.limits(t.timeLimit, t.memoryLimit)
+	cmd := append(t.cmd[:len(t.cmd):len(t.cmd)], run.Args...)
+	return Execute(ctx, cmd, timeLimit, memoryLimit, t.timeRatio, t.chroot, t.workdir, true, stdin, t.files[run.Name], commFile(t.workdir, t.uid, run.Name, ".stderr"))
+}
+
+// fifo is a FIFO between the manager and a run. The judge holds an end of it
+// while the manager runs, so that neither side sees it closed before the
+// other opened it.
+type fifo struct {
+	path string
+	run  *os.File // the end of the run
+	keep *os.File // held by the judge
+}
+
+// newFifo creates a FIFO at path, the run reading it when toRun is set and
+// writing to it otherwise.
+func newFifo(path string, toRun bool) (*fifo, error) {
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		return nil, fmt.Errorf("failed to create fifo: %v", err)
+	}
+	f := &fifo{path: path}
+	// the reading end opens at once without blocking, then the writing
+	// end opens at once as there is a reader
+	r, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	if err := setBlocking(r); err != nil {
+		r.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	w, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		r.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	if toRun {
+		f.run, f.keep = r, w
+	} else {
+		f.run, f.keep = w, r
+	}
+	return f, nil
+}
+
+// setBlocking clears O_NONBLOCK of f, which the processes it is handed to
+// would otherwise inherit.
+func setBlocking(f *os.File) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := conn.Control(func(fd uintptr) {
+		serr = syscall.SetNonblock(int(fd), false)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+func (f *fifo) close() {
+	f.run.Close()
+	f.keep.Close()
+	os.Remove(f.path)
+}
+
+// session runs the runs connected to the manager together with it, and
+// returns their results by name and the result of the manager.
+func (t *communication) session(ctx context.Context) (map[string]*ExecuteResult, *ExecuteResult, error) {
+	var runs []*CommunicationRun
+	var fifos []*fifo
+	defer func() {
+		for _, f := range fifos {
+			f.close()
+		}
+	}()
+	managerCmd := append(t.managerCmd[:len(t.managerCmd):len(t.managerCmd)], t.files[commInput], t.files[commManager], t.answer)
+	var managerTimeLimit float32
+	for _, run := range t.Runs {
+		if !run.Manager {
+			continue
+		}
+		to, err := newFifo(commFile(t.workdir, t.uid, run.Name, ".to"), true)
+		if err != nil {
+			return nil, nil, err
+		}
+		fifos = append(fifos, to)
+		from, err := newFifo(commFile(t.workdir, t.uid, run.Name, ".from"), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		fifos = append(fifos, from)
+		runs = append(runs, run)
+		managerCmd = append(managerCmd, to.path, from.path)
+		timeLimit, _ := run.limits(t.timeLimit, t.memoryLimit)
+		managerTimeLimit += timeLimit * t.timeRatio
+	}
+
+	results := make([]*ExecuteResult, len(runs))
+	errs := make([]error, len(runs)+1)
+	var managerRes *ExecuteResult
+	var wg sync.WaitGroup
+	for i, run := range runs {
+		wg.Add(1)
+		go func(i int, run *CommunicationRun, to, from *fifo) {
+			defer wg.Done()
+			stderr, err := os.OpenFile(commFile(t.workdir, t.uid, run.Name, ".stderr"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer stderr.Close()
+			timeLimit, memoryLimit := run.limits(t.timeLimit, t.memoryLimit)
+			cmd := append(t.cmd[:len(t.cmd):len(t.cmd)], run.Args...)
+			results[i], errs[i] = executeFiles(ctx, cmd, timeLimit, memoryLimit, t.timeRatio, t.chroot, t.workdir, true, to.run, from.run, stderr)
+			// the manager sees the end of the output of the run, and
+			// no longer writes to it
+			from.run.Close()
+			to.run.Close()
+		}(i, run, fifos[2*i], fifos[2*i+1])
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		managerRes, errs[len(runs)] = Execute(ctx, managerCmd, managerTimeLimit, t.memoryLimit*1024*1024, 1., "", t.workdir, false, "-", "-", commFile(t.workdir, t.uid, commManager, ".stderr"))
+		// runs still reading or writing see the manager is gone
+		for _, f := range fifos {
+			f.keep.Close()
+		}
+	}()
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	ret := make(map[string]*ExecuteResult)
+	for i, run := range runs {
+		ret[run.Name] = results[i]
+	}
+	return ret, managerRes, nil
+}
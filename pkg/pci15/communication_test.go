@@ -0,0 +1,100 @@
+package pci15
+
+import "testing"
+
+func TestCommunicationValidate(t *testing.T) {
+	manager := &SourceCode{Source: "manager.cpp", Language: "cpp"}
+	tests := []struct {
+		name string
+		comm *Communication
+		err  string // empty when valid
+	}{
+		{
+			name: "pipeline",
+			comm: &Communication{Runs: []*CommunicationRun{{Name: "encode", Stdin: "input"}, {Name: "decode", Stdin: "encode"}}},
+		},
+		{
+			name: "manager",
+			comm: &Communication{Manager: manager, Runs: []*CommunicationRun{
+				{Name: "alice", Manager: true},
+				{Name: "bob", Manager: true},
+				{Name: "check", Stdin: "manager"},
+			}, Output: "check"},
+		},
+		{
+			name: "no runs",
+			comm: &Communication{},
+			err:  "communication has no runs",
+		},
+		{
+			name: "no name",
+			comm: &Communication{Runs: []*CommunicationRun{{Name: "a"}, {}}},
+			err:  "run 2 has no name",
+		},
+		{
+			name: "reserved name",
+			comm: &Communication{Runs: []*CommunicationRun{{Name: "input"}}},
+			err:  "run 1 can not be named input",
+		},
+		{
+			name: "duplicate",
+			comm: &Communication{Runs: []*CommunicationRun{{Name: "a"}, {Name: "a"}}},
+			err:  "run a appears more than once",
+		},
+		{
+			name: "no manager",
+			comm: &Communication{Runs: []*CommunicationRun{{Name: "a", Manager: true}}},
+			err:  "run a is connected to the manager, but there is none",
+		},
+		{
+			name: "managed run reads",
+			comm: &Communication{Manager: manager, Runs: []*CommunicationRun{{Name: "a", Manager: true, Stdin: "input"}}},
+			err:  "run a is connected to the manager, it can not read input",
+		},
+		{
+			name: "manager output before it runs",
+			comm: &Communication{Manager: manager, Runs: []*CommunicationRun{{Name: "a", Stdin: "manager"}, {Name: "b", Manager: true}}},
+			err:  "run a reads the output of the manager before it runs",
+		},
+		{
+			name: "later run",
+			comm: &Communication{Runs: []*CommunicationRun{{Name: "a", Stdin: "b"}, {Name: "b"}}},
+			err:  "run a reads b, which is not an earlier run",
+		},
+		{
+			name: "managed run output",
+			comm: &Communication{Manager: manager, Runs: []*CommunicationRun{{Name: "a", Manager: true}, {Name: "b", Stdin: "a"}}},
+			err:  "run b reads a, whose output goes to the manager",
+		},
+		{
+			name: "manager unused",
+			comm: &Communication{Manager: manager, Runs: []*CommunicationRun{{Name: "a"}}},
+			err:  "no run is connected to the manager",
+		},
+		{
+			name: "manager output without manager",
+			comm: &Communication{Runs: []*CommunicationRun{{Name: "a"}}, Output: "manager"},
+			err:  "the output of the manager is checked, but there is none",
+		},
+		{
+			name: "output not a run",
+			comm: &Communication{Runs: []*CommunicationRun{{Name: "a"}}, Output: "b"},
+			err:  "the output of b is checked, which is not a run",
+		},
+		{
+			name: "output of a managed run",
+			comm: &Communication{Manager: manager, Runs: []*CommunicationRun{{Name: "a", Manager: true}}, Output: "a"},
+			err:  "the output of a is checked, which goes to the manager",
+		},
+	}
+	for _, tt := range tests {
+		err := tt.comm.validate()
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.err {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.err)
+		}
+	}
+}
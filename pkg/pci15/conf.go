@@ -1,16 +1,43 @@
 package pci15
 
-import "github.com/erjiaqing/PCIJudger2/pkg/hostconn"
+import (
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/artifact"
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn"
+)
 
 type Config struct {
-	Tmp             string        `json:"tmp"`
-	IsDocker        bool          `json:"isDocker"`
-	Problem         string        `json:"problem"`
-	LanguageStorage string        `json:"lang"`
-	ProblemPath     string        `json:"datapath"`
-	MirrorFSConfig  string        `json:"mirrorfs"`
-	MaxJudgeThread  int           `json:"thread"`
-	SupportFiles    string        `json:"supportFiles"`
-	RunAll bool `json:"testrun"`
-	HostSocket      *hostconn.UDP `json:"-"`
+	Tmp             string            `json:"tmp"`
+	IsDocker        bool              `json:"isDocker"`
+	Problem         string            `json:"problem"`
+	LanguageStorage string            `json:"lang"`
+	ProblemPath     string            `json:"datapath"`
+	MirrorFSConfig  string            `json:"mirrorfs"`
+	MaxJudgeThread  int               `json:"thread"`
+	SupportFiles    string            `json:"supportFiles"`
+	RunAll          bool              `json:"testrun"`
+	HostKey         string            `json:"hostKey"` // signs host messages, hostconn.KeyEnv if empty
+	HostSocket      hostconn.Reporter `json:"-"`
+	Events          EventSink         `json:"-"`
+	Trace           bool              `json:"trace"` // embed the phase timing trace in JudgeResult
+	ArtifactDir     string            `json:"artifacts"`
+	ArtifactPolicy  artifact.Policy   `json:"artifactPolicy"`
+	ArtifactMaxSize int64             `json:"artifactMaxSize"`
+	ArtifactTTL     time.Duration     `json:"artifactTTL"`
+	Feedback        FeedbackPolicy    `json:"feedback"`  // overrides the policy of the problem when set
+	TimeLimit       uint64            `json:"timelimit"` // ms, overrides the time limit of the problem when set
+	// limits of archive submissions, defaults when 0
+	ArchiveMaxSize     int64 `json:"archiveMaxSize"`     // bytes of the archive
+	ArchiveMaxFiles    int   `json:"archiveMaxFiles"`    // files and directories unpacked
+	ArchiveMaxUnpacked int64 `json:"archiveMaxUnpacked"` // bytes unpacked
+}
+
+// ArtifactStore returns the store test outputs are kept in, nil when they are
+// not kept.
+func (conf *Config) ArtifactStore() (*artifact.Store, error) {
+	if conf.ArtifactDir == "" || conf.ArtifactPolicy == "" || conf.ArtifactPolicy == artifact.PolicyNone {
+		return nil, nil
+	}
+	return artifact.New(conf.ArtifactDir, conf.ArtifactMaxSize, conf.ArtifactTTL)
 }
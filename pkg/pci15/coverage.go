@@ -0,0 +1,161 @@
+package pci15
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Coverage tells which tests fail which wrong test solutions, those not
+// expected to pass.
+type Coverage struct {
+	Tests     []*TestCoverage     `json:"tests"`
+	Solutions []*SolutionCoverage `json:"sols"`
+}
+
+type TestCoverage struct {
+	Name string `json:"name"`
+	// Verdicts are the verdicts of every solution, in the order of Solutions
+	Verdicts []string `json:"verdicts"`
+	Kills    []string `json:"kills"` // wrong solutions failing the test
+	// Redundant is set when no wrong solution fails the test
+	Redundant bool `json:"redundant,omitempty"`
+}
+
+type SolutionCoverage struct {
+	Source   string   `json:"source"`
+	Wrong    bool     `json:"wrong"`
+	Verdict  string   `json:"verdict"`
+	KilledBy []string `json:"killed_by,omitempty"`
+	// FirstKill is the earliest test failing the solution
+	FirstKill string `json:"first_kill,omitempty"`
+	// Fragile is set when a single test fails the wrong solution
+	Fragile bool `json:"fragile,omitempty"`
+	// Survived is set when no test fails the wrong solution
+	Survived bool `json:"survived,omitempty"`
+}
+
+// expectsAC tells whether s is expected to pass every test.
+func (s *TestSolution) expectsAC() bool {
+	if s.Fails {
+		return false
+	}
+	if s.Verdict != "" {
+		return s.Verdict == "AC"
+	}
+	return len(s.ExpectedVerdict) == 1 && s.expects("AC")
+}
+
+// analyzeCoverage builds the coverage of cases from the results of the test
+// solutions, runs[i] being the result of sols[i]. Solutions failing to
+// compile are left out.
+func analyzeCoverage(cases []TestCase, sols []*TestSolution, runs []*JudgeResult) *Coverage {
+	c := &Coverage{}
+	var judged []*JudgeResult
+	for i, s := range sols {
+		if runs[i].Verdict == "CE" {
+			continue
+		}
+		c.Solutions = append(c.Solutions, &SolutionCoverage{
+			Source:  s.Source,
+			Wrong:   !s.expectsAC(),
+			Verdict: runs[i].Verdict,
+		})
+		judged = append(judged, runs[i])
+	}
+	for i, testCase := range cases {
+		test := &TestCoverage{
+			Name:  testCase.Input,
+			Kills: []string{},
+		}
+		for j, sol := range c.Solutions {
+			verdict := ""
+			if i < len(judged[j].Detail) {
+				verdict = judged[j].Detail[i].Verdict
+			}
+			test.Verdicts = append(test.Verdicts, verdict)
+			// skipped tests did not fail the solution
+			if !sol.Wrong || verdict == "AC" || verdict == "IG" || verdict == "" {
+				continue
+			}
+			test.Kills = append(test.Kills, sol.Source)
+			sol.KilledBy = append(sol.KilledBy, testCase.Input)
+			if sol.FirstKill == "" {
+				sol.FirstKill = testCase.Input
+			}
+		}
+		test.Redundant = len(test.Kills) == 0
+		c.Tests = append(c.Tests, test)
+	}
+	for _, sol := range c.Solutions {
+		if sol.Wrong {
+			sol.Fragile = len(sol.KilledBy) == 1
+			sol.Survived = len(sol.KilledBy) == 0
+		}
+	}
+	return c
+}
+
+// WriteTable writes c as a table of the verdict of every solution on every
+// test, followed by the tests and solutions to look at.
+func (c *Coverage) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprint(tw, "test")
+	for i := range c.Solutions {
+		fmt.Fprintf(tw, "\t#%d", i+1)
+	}
+	fmt.Fprint(tw, "\tkills\t\n")
+	for _, test := range c.Tests {
+		fmt.Fprint(tw, test.Name)
+		for _, v := range test.Verdicts {
+			if v == "" {
+				v = "-"
+			}
+			fmt.Fprintf(tw, "\t%s", v)
+		}
+		flag := ""
+		if test.Redundant {
+			flag = " redundant"
+		}
+		fmt.Fprintf(tw, "\t%d%s\t\n", len(test.Kills), flag)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprint(tw, "#\tsolution\tverdict\tfirst kill\tkilled by\t\n")
+	for i, sol := range c.Solutions {
+		first, by := "", ""
+		switch {
+		case !sol.Wrong:
+			first = "(expected AC)"
+		case sol.Survived:
+			first = "(survived)"
+		default:
+			first = sol.FirstKill
+			by = fmt.Sprintf("%d", len(sol.KilledBy))
+			if sol.Fragile {
+				by += " fragile"
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t\n", i+1, sol.Source, sol.Verdict, first, by)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var redundant []string
+	for _, test := range c.Tests {
+		if test.Redundant {
+			redundant = append(redundant, test.Name)
+		}
+	}
+	if len(redundant) > 0 {
+		_, err := fmt.Fprintf(w, "\nRedundant tests, failing no wrong solution: %s\n", strings.Join(redundant, ", "))
+		return err
+	}
+	return nil
+}
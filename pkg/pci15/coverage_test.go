@@ -0,0 +1,75 @@
+package pci15
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeCoverage(t *testing.T) {
+	cases := []TestCase{{Input: "1.in"}, {Input: "2.in"}, {Input: "3.in"}}
+	run := func(verdicts ...string) *JudgeResult {
+		res := &JudgeResult{Verdict: "AC"}
+		for _, v := range verdicts {
+			res.Detail = append(res.Detail, &JudgeDetail{Verdict: v})
+			if res.Verdict == "AC" && v != "AC" {
+				res.Verdict = v
+			}
+		}
+		return res
+	}
+	sols := []*TestSolution{
+		{SourceCode: SourceCode{Source: "ac"}, ExpectedVerdict: []string{"AC"}},
+		{SourceCode: SourceCode{Source: "wa"}, Verdict: "WA"},
+		{SourceCode: SourceCode{Source: "slow"}, ExpectedVerdict: []string{"AC", "TLE"}},
+		{SourceCode: SourceCode{Source: "lucky"}, Fails: true},
+		{SourceCode: SourceCode{Source: "ce"}, Verdict: "CE"},
+		{SourceCode: SourceCode{Source: "tle"}, Verdict: "TLE"},
+	}
+	runs := []*JudgeResult{
+		run("AC", "AC", "AC"),
+		run("AC", "WA", "WA"),
+		run("AC", "AC", "TLE"),
+		run("AC", "AC", "AC"),
+		{Verdict: "CE", Detail: []*JudgeDetail{{Name: "compile", Verdict: "CE"}}},
+		run("AC", "TLE", "IG"),
+	}
+	c := analyzeCoverage(cases, sols, runs)
+
+	wantTests := []*TestCoverage{
+		{Name: "1.in", Verdicts: []string{"AC", "AC", "AC", "AC", "AC"}, Kills: []string{}, Redundant: true},
+		{Name: "2.in", Verdicts: []string{"AC", "WA", "AC", "AC", "TLE"}, Kills: []string{"wa", "tle"}},
+		{Name: "3.in", Verdicts: []string{"AC", "WA", "TLE", "AC", "IG"}, Kills: []string{"wa", "slow"}},
+	}
+	if !reflect.DeepEqual(c.Tests, wantTests) {
+		for i, test := range c.Tests {
+			t.Errorf("test %d: %+v", i, test)
+		}
+	}
+
+	wantSols := []*SolutionCoverage{
+		{Source: "ac", Verdict: "AC"},
+		{Source: "wa", Wrong: true, Verdict: "WA", KilledBy: []string{"2.in", "3.in"}, FirstKill: "2.in"},
+		{Source: "slow", Wrong: true, Verdict: "TLE", KilledBy: []string{"3.in"}, FirstKill: "3.in", Fragile: true},
+		{Source: "lucky", Wrong: true, Verdict: "AC", Survived: true},
+		{Source: "tle", Wrong: true, Verdict: "TLE", KilledBy: []string{"2.in"}, FirstKill: "2.in", Fragile: true},
+	}
+	if !reflect.DeepEqual(c.Solutions, wantSols) {
+		for i, sol := range c.Solutions {
+			t.Errorf("solution %d: %+v", i, sol)
+		}
+	}
+}
+
+func TestAnalyzeCoverageShortResult(t *testing.T) {
+	// a judgement stopped early has no detail for the last tests
+	cases := []TestCase{{Input: "1.in"}, {Input: "2.in"}}
+	sols := []*TestSolution{{SourceCode: SourceCode{Source: "wa"}, Verdict: "WA"}}
+	runs := []*JudgeResult{{Verdict: "WA", Detail: []*JudgeDetail{{Verdict: "WA"}}}}
+	c := analyzeCoverage(cases, sols, runs)
+	if got := c.Tests[1].Verdicts; !reflect.DeepEqual(got, []string{""}) {
+		t.Errorf("verdicts of the missing test: %q", got)
+	}
+	if !c.Tests[1].Redundant || !c.Solutions[0].Fragile {
+		t.Errorf("got test %+v, solution %+v", c.Tests[1], c.Solutions[0])
+	}
+}
@@ -0,0 +1,170 @@
+package pci15
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/builtin_cmp"
+	"github.com/erjiaqing/PCIJudger2/pkg/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// CustomRun is a run of a submission on input given by the user, outside of
+// any problem.
+type CustomRun struct {
+	Input       string `json:"input"`              // path of the stdin
+	Expected    string `json:"expected,omitempty"` // path of the expected output, optional
+	Checker     string `json:"checker,omitempty"`  // builtin checker comparing with Expected, !diff if empty
+	TimeLimit   uint64 `json:"timelimit"`          // ms
+	MemoryLimit uint64 `json:"memorylimit"`        // MiB
+	OutputLimit int64  `json:"outputlimit"`        // bytes returned of stdout and stderr each
+}
+
+const (
+	defaultCustomTimeLimit   = 1000
+	defaultCustomMemoryLimit = 256
+	defaultCustomOutputLimit = 64 << 10
+)
+
+type CustomResult struct {
+	// Verdict is CE, TLE, ILE, MLE or RE when the run failed, otherwise AC
+	// or WA when there is an expected output and OK when there is not.
+	Verdict         string  `json:"verdict"`
+	CompilerOutput  string  `json:"compiler_output,omitempty"`
+	Stdout          string  `json:"stdout"`
+	Stderr          string  `json:"stderr"`
+	StdoutTruncated bool    `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool    `json:"stderr_truncated,omitempty"`
+	ExeTime         float32 `json:"exe_time"`
+	ExeMemory       uint64  `json:"exe_memory"`
+	ExitCode        int32   `json:"exit_code"`
+	ExitSignal      int32   `json:"exit_signal"`
+	Log             *PCILog `json:"log,omitempty"`
+}
+
+// readCapped reads at most limit bytes of path.
+func readCapped(path string, limit int64) (string, bool, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer fp.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(fp, limit+1))
+	if err != nil {
+		return "", false, err
+	}
+	if int64(len(data)) > limit {
+		return string(data[:limit]), true, nil
+	}
+	return string(data), false, nil
+}
+
+// RunCustom compiles code like Judge does and runs it once on run.Input, in
+// the same sandbox and with the same limits as a test case.
+func RunCustom(ctx context.Context, conf *Config, code *SourceCode, run *CustomRun) (*CustomResult, error) {
+	ctx, span := trace.Start(ctx, "run_custom")
+	span.SetAttr("lang", code.Language)
+	defer span.Finish()
+
+	log := NewPCILog("custom")
+	result := &CustomResult{
+		Log: log,
+	}
+	timeLimit := run.TimeLimit
+	if timeLimit == 0 {
+		timeLimit = defaultCustomTimeLimit
+	}
+	memoryLimit := run.MemoryLimit
+	if memoryLimit == 0 {
+		memoryLimit = defaultCustomMemoryLimit
+	}
+	outputLimit := run.OutputLimit
+	if outputLimit <= 0 {
+		outputLimit = defaultCustomOutputLimit
+	}
+	checker := run.Checker
+	if checker == "" {
+		checker = "!diff"
+	}
+	if _, ok := builtin_cmp.Diff[checker]; run.Expected != "" && !ok {
+		return nil, fmt.Errorf("unknown builtin checker %s", checker)
+	}
+
+	tmpDir, err := filepath.Abs(conf.Tmp)
+	if err != nil {
+		return nil, err
+	}
+	workDir := filepath.Join(tmpDir, GetRandomString())
+	if err := os.MkdirAll(workDir, 0777); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	execCommand, codeLanguage, newCode, compilerOutput, err := compileIn(ctx, conf, code, workDir)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if execCommand == nil {
+		return nil, err
+	}
+	result.CompilerOutput = compilerOutput
+	if err != nil {
+		if newCode.CompileResult == nil {
+			result.CompilerOutput = err.Error()
+		}
+		log.Entry(LevelWarn, "Compilation failed", LogFields{"error": err.Error()})
+		result.Verdict = "CE"
+		return result, nil
+	}
+
+	chrootName, teardown, err := setupMirrorFS(ctx, conf, log)
+	if err != nil {
+		return nil, err
+	}
+	defer teardown()
+
+	stdoutFile := filepath.Join(workDir, "custom.stdout")
+	stderrFile := filepath.Join(workDir, "custom.stderr")
+	logrus.Infof("Running custom input %s", run.Input)
+	execResult, err := Execute(ctx, execCommand.Execute, float32(timeLimit)/1000., memoryLimit*1024*1024, codeLanguage.Execute.TimeRatio, filepath.Join("/fj_tmp/mirrorfs", chrootName), workDir, true, run.Input, stdoutFile, stderrFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute code: %v", err)
+	}
+	log.Entry(LevelInfo, "Execution finished", LogFields{
+		"cputime":  execResult.CPUTime,
+		"memory":   execResult.ExeMemory,
+		"exitcode": execResult.ExitCode,
+		"exceeded": execResult.ExitReason,
+	})
+	result.ExeTime = execResult.CPUTime
+	result.ExeMemory = execResult.ExeMemory / 1024
+	result.ExitCode = execResult.ExitCode
+	result.ExitSignal = execResult.ExitSignal
+	if execResult.ExitSignal == 0 {
+		result.ExitSignal = -execResult.TermSignal
+	}
+	result.Stdout, result.StdoutTruncated, _ = readCapped(stdoutFile, outputLimit)
+	result.Stderr, result.StderrTruncated, _ = readCapped(stderrFile, outputLimit)
+
+	switch {
+	case execResult.ExitReason != "none":
+		result.Verdict = execResult.ExitReason
+	case run.Expected == "":
+		result.Verdict = "OK"
+	default:
+		same, err := builtin_cmp.Diff[checker](stdoutFile, run.Expected)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare output: %v", err)
+		}
+		result.Verdict = "WA"
+		if same {
+			result.Verdict = "AC"
+		}
+	}
+	span.SetAttr("verdict", result.Verdict)
+	return result, nil
+}
@@ -0,0 +1,191 @@
+package pci15
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn"
+	"github.com/erjiaqing/PCIJudger2/pkg/hostconn/message"
+	"github.com/sirupsen/logrus"
+)
+
+type EventType string
+
+const (
+	// EventFetchStarted is emitted by whoever fetches the problem before
+	// calling Judge, like the judge daemon.
+	EventFetchStarted    EventType = "fetch_started"
+	EventJudgeStarted    EventType = "judge_started"
+	EventCompileStarted  EventType = "compile_started"
+	EventCompileFinished EventType = "compile_finished"
+	EventTestStarted     EventType = "test_started"
+	EventCheckStarted    EventType = "check_started"
+	EventTestFinished    EventType = "test_finished"
+	EventJudgeFinished   EventType = "judge_finished"
+)
+
+// JudgeEvent is emitted by Judge while it makes progress. Test is the 1-based
+// number of the test case, Judged is how many leading test cases are done.
+type JudgeEvent struct {
+	Type   EventType    `json:"type"`
+	Time   time.Time    `json:"time"`
+	Test   int          `json:"test,omitempty"`
+	Judged int          `json:"judged,omitempty"`
+	Total  int          `json:"total,omitempty"`
+	Detail *JudgeDetail `json:"detail,omitempty"`
+	Result *JudgeResult `json:"result,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// EventSink receives judge events. Judge serializes calls to Emit, but a sink
+// shared by several judgements has to be goroutine-safe on its own.
+type EventSink interface {
+	Emit(ev *JudgeEvent)
+}
+
+// MultiSink emits every event to all of its sinks in order.
+type MultiSink []EventSink
+
+func (m MultiSink) Emit(ev *JudgeEvent) {
+	for _, s := range m {
+		s.Emit(ev)
+	}
+}
+
+// HostStatus is the status of a judgement as reported over hostconn, built
+// from its events in order.
+type HostStatus struct {
+	hostconn.Status
+}
+
+// Apply updates the status with ev, it returns false if ev does not change
+// the phase of the judgement.
+func (h *HostStatus) Apply(ev *JudgeEvent) bool {
+	st := &h.Status
+	if ev.Total > 0 {
+		st.Total = ev.Total
+	}
+	switch ev.Type {
+	case EventFetchStarted:
+		st.Phase = message.Phase_FETCHING_PROBLEM
+	case EventJudgeStarted:
+		st.Phase = message.Phase_PREPARING
+	case EventCompileStarted:
+		st.Phase = message.Phase_COMPILING
+	case EventCompileFinished:
+		st.Phase = message.Phase_RUNNING
+		if ev.Detail != nil && ev.Detail.Verdict != "AC" {
+			st.Verdict = ev.Detail.Verdict
+		}
+	case EventTestStarted:
+		st.Phase = message.Phase_RUNNING
+		st.Test = ev.Test
+	case EventCheckStarted:
+		st.Phase = message.Phase_CHECKING
+		st.Test = ev.Test
+	case EventTestFinished:
+		st.Phase = message.Phase_RUNNING
+		st.Test = ev.Test
+		if st.Total > 0 {
+			st.Progress = 100 * ev.Judged / st.Total
+		}
+		if ev.Detail != nil && ev.Detail.Verdict != "AC" && (st.Verdict == "" || st.Verdict == "AC") {
+			st.Verdict = ev.Detail.Verdict
+		} else if st.Verdict == "" {
+			st.Verdict = "AC"
+		}
+	case EventJudgeFinished:
+		st.Progress = 100
+		if ev.Error != "" {
+			st.Phase = message.Phase_FAILED
+			st.Verdict = "SE"
+		} else {
+			st.Phase = message.Phase_FINISHED
+			if ev.Result != nil {
+				st.Verdict = ev.Result.Verdict
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// HostSink translates events into the phases sent over hostconn. A HostSink
+// keeps the state of a single judgement.
+type HostSink struct {
+	Host   hostconn.Reporter
+	status HostStatus
+}
+
+func (h *HostSink) Emit(ev *JudgeEvent) {
+	if !h.status.Apply(ev) {
+		return
+	}
+	status := h.status.Status
+	h.Host.SendStatus(&status)
+}
+
+// JSONLinesSink writes every event as one line of JSON.
+type JSONLinesSink struct {
+	mut sync.Mutex
+	w   io.Writer
+}
+
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+func (s *JSONLinesSink) Emit(ev *JudgeEvent) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		logrus.Errorf("Failed to marshal event: %v", err)
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.w.Write(append(data, '\n'))
+}
+
+// ChanSink sends every event to C. Emit blocks until the event is received,
+// so the consumer must keep reading until the judge_finished event.
+type ChanSink struct {
+	C chan *JudgeEvent
+}
+
+func NewChanSink(buffer int) *ChanSink {
+	return &ChanSink{
+		C: make(chan *JudgeEvent, buffer),
+	}
+}
+
+func (s *ChanSink) Emit(ev *JudgeEvent) {
+	s.C <- ev
+}
+
+type lockedSink struct {
+	mut  sync.Mutex
+	sink EventSink
+}
+
+func (s *lockedSink) Emit(ev *JudgeEvent) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.sink.Emit(ev)
+}
+
+func (conf *Config) eventSink() EventSink {
+	sinks := MultiSink{}
+	if conf.HostSocket != nil {
+		sinks = append(sinks, &HostSink{Host: conf.HostSocket})
+	}
+	if conf.Events != nil {
+		sinks = append(sinks, conf.Events)
+	}
+	return &lockedSink{sink: sinks}
+}
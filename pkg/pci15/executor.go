@@ -1,12 +1,18 @@
 package pci15
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
+	"sync"
 
+	"github.com/erjiaqing/PCIJudger2/pkg/metrics"
+	"github.com/erjiaqing/PCIJudger2/pkg/trace"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,7 +26,65 @@ type ExecuteResult struct {
 	ExitReason string  `json:"exceeded"`
 }
 
-func Execute(cmd []string, timeLimit float32, memoryLimit uint64, timeRatio float32, chroot, workdir string, limitSyscall bool, stdin, stdout, stderr string) (*ExecuteResult, error) {
+// Execute runs cmd under lrun. Paths of stdin, stdout and stderr are host
+// paths, "-" leaves the stream untouched. workdir is the directory the command
+// runs in, inside the chroot when limitSyscall is set.
+func Execute(ctx context.Context, cmd []string, timeLimit float32, memoryLimit uint64, timeRatio float32, chroot, workdir string, limitSyscall bool, stdin, stdout, stderr string) (*ExecuteResult, error) {
+	ctx, span := trace.Start(ctx, "execute")
+	span.SetAttr("cmd", strings.Join(cmd, " "))
+	res, err := execute(ctx, cmd, timeLimit, memoryLimit, timeRatio, chroot, workdir, limitSyscall, stdin, stdout, stderr)
+	traceResult(span, "", res)
+	span.SetError(err)
+	span.Finish()
+	return res, err
+}
+
+// traceResult records res on span, prefixing the attributes with prefix.
+func traceResult(span *trace.Span, prefix string, res *ExecuteResult) {
+	if res == nil {
+		return
+	}
+	span.SetAttr(prefix+"cputime", res.CPUTime)
+	span.SetAttr(prefix+"realtime", res.RealTime)
+	span.SetAttr(prefix+"memory", res.ExeMemory)
+	span.SetAttr(prefix+"exceeded", res.ExitReason)
+}
+
+func execute(ctx context.Context, cmd []string, timeLimit float32, memoryLimit uint64, timeRatio float32, chroot, workdir string, limitSyscall bool, stdin, stdout, stderr string) (*ExecuteResult, error) {
+	var in, out, errOut *os.File
+	if stdin != "-" {
+		fp, err := os.Open(stdin)
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+		in = fp
+	}
+	if stdout != "-" {
+		fp, err := os.OpenFile(stdout, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+		out = fp
+		if stderr == stdout {
+			errOut = out
+		}
+	}
+	if stderr != "-" && stderr != stdout {
+		fp, err := os.OpenFile(stderr, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+		errOut = fp
+	}
+	return executeFiles(ctx, cmd, timeLimit, memoryLimit, timeRatio, chroot, workdir, limitSyscall, in, out, errOut)
+}
+
+// executeFiles is execute with the streams already open, a nil stream is left
+// untouched.
+func executeFiles(ctx context.Context, cmd []string, timeLimit float32, memoryLimit uint64, timeRatio float32, chroot, workdir string, limitSyscall bool, stdin, stdout, stderr *os.File) (*ExecuteResult, error) {
 	cpuTimelimit := timeLimit * timeRatio
 	realTimelimit := cpuTimelimit * 1.5
 	runCommand := []string{
@@ -54,37 +118,26 @@ func Execute(cmd []string, timeLimit float32, memoryLimit uint64, timeRatio floa
 	runCommand = append(runCommand, cmd...)
 	logrus.Infof("Exec: %v", runCommand)
 	exe := exec.Command(runCommand[0], runCommand[1:]...)
-	if stdin != "-" {
-		fp, err := os.Open(stdin)
-		if err != nil {
-			return nil, err
-		}
-		exe.Stdin = fp
+	if !limitSyscall && workdir != "" && workdir != "-" {
+		exe.Dir = workdir
 	}
-	if stdout != "-" {
-		fp, err := os.OpenFile(stdout, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-		if err != nil {
-			return nil, err
-		}
-		exe.Stdout = fp
-		if stderr == stdout {
-			exe.Stderr = exe.Stdout
-		}
+	if stdin != nil {
+		exe.Stdin = stdin
 	}
-	if stderr != "-" && stderr != stdout {
-		fp, err := os.OpenFile(stderr, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-		if err != nil {
-			return nil, err
-		}
-		exe.Stderr = fp
+	if stdout != nil {
+		exe.Stdout = stdout
+	}
+	if stderr != nil {
+		exe.Stderr = stderr
 	}
 	resultYaml, err := ioutil.TempFile("", "runres")
 	if err != nil {
 		return nil, err
 	}
 	resultYamlName := resultYaml.Name()
+	defer os.Remove(resultYamlName)
 	exe.ExtraFiles = []*os.File{resultYaml}
-	err = exe.Run()
+	err = runContext(ctx, exe)
 	resultYaml.Close()
 	if err != nil {
 		return nil, err
@@ -112,10 +165,27 @@ func Execute(cmd []string, timeLimit float32, memoryLimit uint64, timeRatio floa
 		}
 	}
 	executorOutput.ExitReason = outputExitReason
+	metrics.Executions.Inc(outputExitReason)
 	return executorOutput, nil
 }
 
-func ExecuteInteractor(cmd, interactor []string, timeLimit float32, memoryLimit uint64, timeRatio float32, chroot, workdir string, limitSyscall bool) (*ExecuteResult, *ExecuteResult, error) {
+// ExecuteInteractor runs cmd inside the sandbox with its stdin and stdout
+// connected to the interactor, which runs outside the chroot in interdir.
+// When transcript is not empty, the data exchanged is relayed through the
+// judge and written to it.
+func ExecuteInteractor(ctx context.Context, cmd, interactor []string, timeLimit float32, memoryLimit uint64, timeRatio float32, chroot, workdir, interdir string, limitSyscall bool, transcript string) (*ExecuteResult, *ExecuteResult, error) {
+	ctx, span := trace.Start(ctx, "execute_interactor")
+	span.SetAttr("cmd", strings.Join(cmd, " "))
+	span.SetAttr("interactor", strings.Join(interactor, " "))
+	res, interRes, err := executeInteractor(ctx, cmd, interactor, timeLimit, memoryLimit, timeRatio, chroot, workdir, interdir, limitSyscall, transcript)
+	traceResult(span, "", res)
+	traceResult(span, "interactor.", interRes)
+	span.SetError(err)
+	span.Finish()
+	return res, interRes, err
+}
+
+func executeInteractor(ctx context.Context, cmd, interactor []string, timeLimit float32, memoryLimit uint64, timeRatio float32, chroot, workdir, interdir string, limitSyscall bool, transcript string) (*ExecuteResult, *ExecuteResult, error) {
 	cpuTimelimit := timeLimit * timeRatio
 	realTimelimit := cpuTimelimit * 3
 	runCommand := []string{
@@ -170,19 +240,38 @@ func ExecuteInteractor(cmd, interactor []string, timeLimit float32, memoryLimit
 	//------
 	exeProgram := exec.Command(runCommand[0], runCommand[1:]...)
 	exeInteractor := exec.Command(interactorCommand[0], interactorCommand[1:]...)
+	exeInteractor.Dir = interdir
 	//------
-	if pr, iw, err := os.Pipe(); err != nil {
+	pr, iw, err := os.Pipe()
+	if err != nil {
 		return nil, nil, err
-	} else {
-		exeProgram.Stdin = pr
-		exeInteractor.Stdout = iw
 	}
+	defer pr.Close()
+	defer iw.Close()
+	exeProgram.Stdin = pr
+	exeInteractor.Stdout = iw
 
-	if ir, pw, err := os.Pipe(); err != nil {
+	ir, pw, err := os.Pipe()
+	if err != nil {
 		return nil, nil, err
-	} else {
-		exeProgram.Stdout = pw
-		exeInteractor.Stdin = ir
+	}
+	defer ir.Close()
+	defer pw.Close()
+	exeProgram.Stdout = pw
+	exeInteractor.Stdin = ir
+
+	var tr *transcriptFile
+	if transcript != "" {
+		fp, err := os.OpenFile(transcript, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer fp.Close()
+		tr = &transcriptFile{fp: fp}
+		// exec copies into non-file writers itself, the copy ends when the
+		// process closes its stdout
+		exeProgram.Stdout = tr.relay("program", pw)
+		exeInteractor.Stdout = tr.relay("interactor", iw)
 	}
 	///
 	programResultYaml, err := ioutil.TempFile("", "runres0")
@@ -190,6 +279,7 @@ func ExecuteInteractor(cmd, interactor []string, timeLimit float32, memoryLimit
 		return nil, nil, err
 	}
 	programResultYamlName := programResultYaml.Name()
+	defer os.Remove(programResultYamlName)
 	exeProgram.ExtraFiles = []*os.File{programResultYaml}
 	///
 	interactorResultYaml, err := ioutil.TempFile("", "runres1")
@@ -197,17 +287,45 @@ func ExecuteInteractor(cmd, interactor []string, timeLimit float32, memoryLimit
 		return nil, nil, err
 	}
 	interactorResultYamlName := interactorResultYaml.Name()
+	defer os.Remove(interactorResultYamlName)
 	exeInteractor.ExtraFiles = []*os.File{interactorResultYaml}
 	///
-	exeInteractor.Start()
-	exeProgram.Start()
+	waitInteractor, err := startContext(ctx, exeInteractor)
+	if err != nil {
+		interactorResultYaml.Close()
+		programResultYaml.Close()
+		return nil, nil, err
+	}
+	waitProgram, err := startContext(ctx, exeProgram)
+	if err != nil {
+		exeInteractor.Process.Kill()
+		if tr != nil {
+			pr.Close()
+		}
+		waitInteractor()
+		interactorResultYaml.Close()
+		programResultYaml.Close()
+		return nil, nil, err
+	}
 	///
-	exeInteractor.Wait()
-	exeProgram.Wait()
+	if tr != nil {
+		// only the children may hold the reading ends, so that relaying to
+		// an exited process fails instead of blocking, and the writing
+		// ends are closed once relayed to pass on EOF
+		pr.Close()
+		ir.Close()
+		waitInteractor()
+		iw.Close()
+		waitProgram()
+		pw.Close()
+	} else {
+		waitInteractor()
+		waitProgram()
+	}
 	///
 	interactorResultYaml.Close()
 	programResultYaml.Close()
-	if err != nil {
+	if err := ctx.Err(); err != nil {
 		return nil, nil, err
 	}
 	executorOutput := &ExecuteResult{}
@@ -236,5 +354,39 @@ func ExecuteInteractor(cmd, interactor []string, timeLimit float32, memoryLimit
 		}
 	}
 	executorOutput.ExitReason = outputExitReason
+	metrics.Executions.Inc(outputExitReason)
 	return executorOutput, interactorOutput, nil
 }
+
+// transcriptFile records the data relayed between a program and its
+// interactor, with a header line whenever the direction changes.
+type transcriptFile struct {
+	mut  sync.Mutex
+	fp   *os.File
+	last string
+}
+
+type relayWriter struct {
+	t    *transcriptFile
+	from string
+	w    io.Writer
+}
+
+func (t *transcriptFile) relay(from string, w io.Writer) io.Writer {
+	return &relayWriter{t: t, from: from, w: w}
+}
+
+func (r *relayWriter) Write(p []byte) (int, error) {
+	n, err := r.w.Write(p)
+	r.t.mut.Lock()
+	defer r.t.mut.Unlock()
+	if r.t.last != r.from {
+		if r.t.last != "" {
+			fmt.Fprintln(r.t.fp)
+		}
+		fmt.Fprintf(r.t.fp, "[%s]\n", r.from)
+		r.t.last = r.from
+	}
+	r.t.fp.Write(p[:n])
+	return n, err
+}
@@ -0,0 +1,173 @@
+package pci15
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SubtaskExpectation is the outcome expected on a group of tests, given by
+// their 1-based index or their input.
+type SubtaskExpectation struct {
+	Name      string   `json:"name,omitempty"`
+	Case      []int    `json:"case,omitempty"`
+	CaseInput []string `json:"caseInput,omitempty"`
+	// Verdict is AC when every test of the group passes, otherwise the
+	// verdict of its first test failed
+	Verdict string `json:"verdict,omitempty"`
+	Score   *int   `json:"score,omitempty"`
+	Fails   bool   `json:"fails,omitempty"`
+}
+
+// TestExpectation lists the verdicts the tests matched by Test may get. Test
+// is a 1-based index or a pattern on the input, as filepath.Match takes it.
+type TestExpectation struct {
+	Test    string   `json:"test"`
+	Verdict []string `json:"verdict"`
+}
+
+func (t *TestExpectation) match(index int, testCase *TestCase) bool {
+	if n, err := strconv.Atoi(t.Test); err == nil {
+		return n == index+1
+	}
+	ok, _ := filepath.Match(t.Test, testCase.Input)
+	return ok
+}
+
+func (s *SubtaskExpectation) String() string {
+	if s.Name != "" {
+		return fmt.Sprintf("subtask %s", s.Name)
+	}
+	return "subtask"
+}
+
+func (s *SubtaskExpectation) contains(index int, testCase *TestCase) bool {
+	for _, c := range s.Case {
+		if c == index+1 {
+			return true
+		}
+	}
+	for _, in := range s.CaseInput {
+		if in == testCase.Input {
+			return true
+		}
+	}
+	return false
+}
+
+// hasExpectations tells whether s expects more than ExpectedVerdict.
+func (s *TestSolution) hasExpectations() bool {
+	return s.Verdict != "" || s.Score != nil || s.MinScore != nil || s.MaxScore != nil || s.Fails || len(s.Subtasks) > 0 || len(s.Tests) > 0
+}
+
+func containsVerdict(verdicts []string, verdict string) bool {
+	for _, v := range verdicts {
+		if v == verdict {
+			return true
+		}
+	}
+	return false
+}
+
+// expects tells whether verdict is one of the expected verdicts of s.
+func (s *TestSolution) expects(verdict string) bool {
+	return containsVerdict(s.ExpectedVerdict, verdict)
+}
+
+// Violation is an expectation of a test solution not met.
+type Violation struct {
+	Test    int    `json:"test,omitempty"` // 1-based, 0 when not about a single test
+	Message string `json:"message"`
+}
+
+// violations checks the result of s against what it expects, and explains
+// every expectation not met. cases are the tests of the problem, in the
+// order of res.Detail.
+func (s *TestSolution) violations(res *JudgeResult, cases []TestCase) []*Violation {
+	var ret []*Violation
+	add := func(test int, format string, args ...interface{}) {
+		ret = append(ret, &Violation{Test: test, Message: fmt.Sprintf(format, args...)})
+	}
+	details := res.Detail
+	if n := len(details); n > 0 && details[n-1].Name == "compile" {
+		// Judge appends the compile detail after the tests, unless nothing
+		// was compiled
+		details = details[:n-1]
+	}
+	if res.Verdict == "CE" {
+		// the only detail is the compile one
+		details = nil
+		if s.Verdict == "" && !s.expects("CE") {
+			add(0, "does not compile")
+		}
+	}
+
+	if len(s.ExpectedVerdict) > 0 || !s.hasExpectations() {
+		for i, detail := range details {
+			// No IG should found
+			if !containsVerdict(s.ExpectedVerdict, detail.Verdict) {
+				add(i+1, "test %d (%s) got %s, expected one of %s", i+1, cases[i].Input, detail.Verdict, strings.Join(s.ExpectedVerdict, ", "))
+			}
+		}
+	}
+	if s.Verdict != "" && res.Verdict != s.Verdict {
+		add(0, "verdict is %s, expected %s", res.Verdict, s.Verdict)
+	}
+	if s.Score != nil && res.Score != *s.Score {
+		add(0, "score is %d, expected %d", res.Score, *s.Score)
+	}
+	if s.MinScore != nil && res.Score < *s.MinScore {
+		add(0, "score is %d, expected at least %d", res.Score, *s.MinScore)
+	}
+	if s.MaxScore != nil && res.Score > *s.MaxScore {
+		add(0, "score is %d, expected at most %d", res.Score, *s.MaxScore)
+	}
+	if s.Fails && res.Verdict == "AC" {
+		add(0, "passed every test, expected to fail at least one")
+	}
+
+	for _, t := range s.Tests {
+		matched := false
+		for i, detail := range details {
+			if !t.match(i, &cases[i]) {
+				continue
+			}
+			matched = true
+			if !containsVerdict(t.Verdict, detail.Verdict) {
+				add(i+1, "test %d (%s) got %s, expected one of %s by %s", i+1, cases[i].Input, detail.Verdict, strings.Join(t.Verdict, ", "), t.Test)
+			}
+		}
+		if !matched {
+			add(0, "no test matches %s", t.Test)
+		}
+	}
+
+	for _, sub := range s.Subtasks {
+		verdict, score, count := "AC", 0, 0
+		for i, detail := range details {
+			if !sub.contains(i, &cases[i]) {
+				continue
+			}
+			count++
+			score += detail.Score
+			if verdict == "AC" && detail.Verdict != "AC" {
+				verdict = detail.Verdict
+			}
+		}
+		if count == 0 {
+			add(0, "%s has no test", sub)
+			continue
+		}
+		if sub.Verdict != "" && verdict != sub.Verdict {
+			add(0, "%s got %s, expected %s", sub, verdict, sub.Verdict)
+		}
+		if sub.Score != nil && score != *sub.Score {
+			add(0, "%s scored %d, expected %d", sub, score, *sub.Score)
+		}
+		if sub.Fails && verdict == "AC" {
+			add(0, "%s passed every test, expected to fail at least one", sub)
+		}
+	}
+	return ret
+}
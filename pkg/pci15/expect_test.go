@@ -0,0 +1,128 @@
+package pci15
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestViolations(t *testing.T) {
+	cases := []TestCase{{Input: "1.in"}, {Input: "2.in"}, {Input: "big/3.in"}, {Input: "big/4.in"}}
+	// result has a detail for every verdict, the one after the tests is the
+	// compile detail
+	result := func(verdicts ...string) *JudgeResult {
+		res := &JudgeResult{Verdict: "AC"}
+		for i, v := range verdicts {
+			if i == len(cases) {
+				res.Detail = append(res.Detail, &JudgeDetail{Name: "compile", Verdict: v})
+				break
+			}
+			detail := &JudgeDetail{Name: "test", Verdict: v}
+			if v == "AC" {
+				detail.Score = 25
+			}
+			res.Score += detail.Score
+			if res.Verdict == "AC" && v != "AC" {
+				res.Verdict = v
+			}
+			res.Detail = append(res.Detail, detail)
+		}
+		return res
+	}
+	compileError := &JudgeResult{Verdict: "CE", Detail: []*JudgeDetail{{Name: "compile", Verdict: "CE"}}}
+	score := func(n int) *int { return &n }
+
+	tests := []struct {
+		name string
+		sol  TestSolution
+		res  *JudgeResult
+		want []*Violation
+	}{
+		{
+			name: "expected verdicts",
+			sol:  TestSolution{ExpectedVerdict: []string{"AC", "TLE"}},
+			res:  result("AC", "TLE", "WA", "AC", "AC"),
+			want: []*Violation{{3, "test 3 (big/3.in) got WA, expected one of AC, TLE"}},
+		},
+		{
+			name: "no compile detail",
+			sol:  TestSolution{ExpectedVerdict: []string{"AC"}},
+			res:  result("AC", "AC", "AC", "WA"),
+			want: []*Violation{{4, "test 4 (big/4.in) got WA, expected one of AC"}},
+		},
+		{
+			name: "verdict and score",
+			sol:  TestSolution{Verdict: "TLE", Score: score(50), MinScore: score(60), MaxScore: score(40)},
+			res:  result("AC", "WA", "AC", "RE", "AC"),
+			want: []*Violation{
+				{0, "verdict is WA, expected TLE"},
+				{0, "score is 50, expected at least 60"},
+				{0, "score is 50, expected at most 40"},
+			},
+		},
+		{
+			name: "met",
+			sol:  TestSolution{Verdict: "WA", Score: score(75), Fails: true},
+			res:  result("AC", "WA", "AC", "AC", "AC"),
+		},
+		{
+			name: "fails",
+			sol:  TestSolution{Fails: true},
+			res:  result("AC", "AC", "AC", "AC", "AC"),
+			want: []*Violation{{0, "passed every test, expected to fail at least one"}},
+		},
+		{
+			name: "compile error",
+			sol:  TestSolution{ExpectedVerdict: []string{"AC"}, Tests: []*TestExpectation{{Test: "1", Verdict: []string{"AC"}}}},
+			res:  compileError,
+			want: []*Violation{{0, "does not compile"}, {0, "no test matches 1"}},
+		},
+		{
+			name: "expected compile error",
+			sol:  TestSolution{ExpectedVerdict: []string{"CE"}},
+			res:  compileError,
+		},
+		{
+			name: "tests",
+			sol: TestSolution{Tests: []*TestExpectation{
+				{Test: "2", Verdict: []string{"AC"}},
+				{Test: "big/*", Verdict: []string{"TLE", "AC"}},
+				{Test: "9", Verdict: []string{"AC"}},
+			}},
+			res: result("WA", "WA", "TLE", "RE", "AC"),
+			want: []*Violation{
+				{2, "test 2 (2.in) got WA, expected one of AC by 2"},
+				{4, "test 4 (big/4.in) got RE, expected one of TLE, AC by big/*"},
+				{0, "no test matches 9"},
+			},
+		},
+		{
+			name: "subtasks",
+			sol: TestSolution{Subtasks: []*SubtaskExpectation{
+				{Name: "small", Case: []int{1, 2}, Verdict: "AC", Score: score(50)},
+				{Name: "big", CaseInput: []string{"big/3.in", "big/4.in"}, Verdict: "TLE", Fails: true},
+				{Name: "all", Case: []int{1, 2}, CaseInput: []string{"big/4.in"}, Fails: true},
+				{Case: []int{9}},
+			}},
+			res: result("AC", "WA", "AC", "AC", "AC"),
+			want: []*Violation{
+				{0, "subtask small got WA, expected AC"},
+				{0, "subtask small scored 25, expected 50"},
+				{0, "subtask big got AC, expected TLE"},
+				{0, "subtask big passed every test, expected to fail at least one"},
+				{0, "subtask has no test"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := tt.sol.violations(tt.res, cases)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:", tt.name)
+			for _, v := range got {
+				t.Errorf("  got %d: %s", v.Test, v.Message)
+			}
+			for _, v := range tt.want {
+				t.Errorf("  want %d: %s", v.Test, v.Message)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package pci15
+
+import (
+	"fmt"
+	"sync"
+)
+
+// FeedbackPolicy decides how much of the result contestants see.
+type FeedbackPolicy string
+
+const (
+	// FeedbackFull shows everything, it is the default
+	FeedbackFull FeedbackPolicy = "full"
+	// FeedbackExamples shows full detail for example tests and only verdict,
+	// score, time and memory for hidden ones
+	FeedbackExamples FeedbackPolicy = "examples"
+	// FeedbackFirstFailure is FeedbackExamples, but hides every test after
+	// the first one failed
+	FeedbackFirstFailure FeedbackPolicy = "first_failure"
+)
+
+func ParseFeedbackPolicy(s string) (FeedbackPolicy, error) {
+	switch FeedbackPolicy(s) {
+	case "":
+		return FeedbackFull, nil
+	case FeedbackFull, FeedbackExamples, FeedbackFirstFailure:
+		return FeedbackPolicy(s), nil
+	}
+	return FeedbackFull, fmt.Errorf("unknown feedback policy %s, expected full, examples or first_failure", s)
+}
+
+// feedbackPolicy is the policy of conf if set, otherwise the one of the problem.
+func feedbackPolicy(conf *Config, problemConf *ProblemConfig) FeedbackPolicy {
+	if conf.Feedback != "" {
+		return conf.Feedback
+	}
+	if problemConf.Feedback != "" {
+		return problemConf.Feedback
+	}
+	return FeedbackFull
+}
+
+// redactDetail returns what contestants may see of the detail of a test.
+func redactDetail(policy FeedbackPolicy, testCase *TestCase, detail *JudgeDetail) *JudgeDetail {
+	if detail == nil || policy == FeedbackFull || policy == "" {
+		return detail
+	}
+	if testCase.Example {
+		ret := *detail
+		ret.Artifacts = nil
+		return &ret
+	}
+	return &JudgeDetail{
+		Name:      detail.Name,
+		Score:     detail.Score,
+		Verdict:   detail.Verdict,
+		ExeTime:   detail.ExeTime,
+		ExeMemory: detail.ExeMemory,
+	}
+}
+
+// applyFeedback replaces the details of j by what contestants may see, the
+// result as judged is kept for Unredacted. cases are the test cases in the
+// order of Detail, the compile detail after them has no test case.
+func (j *JudgeResult) applyFeedback(policy FeedbackPolicy, cases []TestCase) {
+	if policy == FeedbackFull || policy == "" {
+		return
+	}
+	full := *j
+	j.full = &full
+	j.Log = nil
+	j.Trace = nil
+	j.Detail = make([]*JudgeDetail, 0, len(full.Detail))
+	failed := false
+	for i, detail := range full.Detail {
+		if i >= len(cases) {
+			// compile output contains nothing hidden
+			j.Detail = append(j.Detail, detail)
+			continue
+		}
+		if failed {
+			continue
+		}
+		j.Detail = append(j.Detail, redactDetail(policy, &cases[i], detail))
+		if policy == FeedbackFirstFailure && failedTest(detail) {
+			failed = true
+		}
+	}
+}
+
+// failedTest tells whether detail hides the tests after it under
+// FeedbackFirstFailure.
+func failedTest(detail *JudgeDetail) bool {
+	return detail != nil && detail.Verdict != "AC" && detail.Verdict != "IG"
+}
+
+// firstFailureSink shows in live events no more than FeedbackFirstFailure
+// shows in the result. Tests are judged concurrently, so test_finished events
+// are held back until the tests before them are finished, and released in
+// order, those after the first failed test without their detail. Other
+// events of a test are only passed while it is the first one not finished
+// and nothing failed yet.
+type firstFailureSink struct {
+	sink EventSink
+
+	mut    sync.Mutex
+	next   int // index of the first test not released
+	failed bool
+	held   map[int]*JudgeEvent
+}
+
+func newFirstFailureSink(sink EventSink) *firstFailureSink {
+	return &firstFailureSink{
+		sink: sink,
+		held: make(map[int]*JudgeEvent),
+	}
+}
+
+func (s *firstFailureSink) Emit(ev *JudgeEvent) {
+	if ev.Test == 0 {
+		s.sink.Emit(ev)
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if ev.Type != EventTestFinished {
+		if ev.Test-1 == s.next && !s.failed {
+			s.sink.Emit(ev)
+		}
+		return
+	}
+	s.held[ev.Test-1] = ev
+	for {
+		held, ok := s.held[s.next]
+		if !ok {
+			return
+		}
+		delete(s.held, s.next)
+		s.next++
+		ev := *held
+		ev.Judged = s.next
+		if s.failed {
+			ev.Detail = nil
+		}
+		s.failed = s.failed || failedTest(held.Detail)
+		s.sink.Emit(&ev)
+	}
+}
+
+// Unredacted returns the result as judged, with nothing hidden by the
+// feedback policy. It is meant for admins.
+func (j *JudgeResult) Unredacted() *JudgeResult {
+	if j.full != nil {
+		return j.full
+	}
+	return j
+}
@@ -0,0 +1,139 @@
+package pci15
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyFeedback(t *testing.T) {
+	cases := []TestCase{{Example: true}, {}, {}}
+	detail := func(name, verdict string) *JudgeDetail {
+		return &JudgeDetail{Name: name, Verdict: verdict, Input: "in", Output: "out", Answer: "ans", Comment: "comment", Score: 1, ExeTime: 0.5}
+	}
+	hidden := func(name, verdict string) *JudgeDetail {
+		return &JudgeDetail{Name: name, Verdict: verdict, Score: 1, ExeTime: 0.5}
+	}
+	tests := []struct {
+		name   string
+		policy FeedbackPolicy
+		detail []*JudgeDetail
+		want   []*JudgeDetail
+	}{
+		{
+			name:   "full",
+			policy: FeedbackFull,
+			detail: []*JudgeDetail{detail("1", "AC"), detail("2", "WA"), detail("3", "AC")},
+			want:   []*JudgeDetail{detail("1", "AC"), detail("2", "WA"), detail("3", "AC")},
+		},
+		{
+			name:   "examples",
+			policy: FeedbackExamples,
+			detail: []*JudgeDetail{detail("1", "AC"), detail("2", "WA"), detail("3", "AC")},
+			want:   []*JudgeDetail{detail("1", "AC"), hidden("2", "WA"), hidden("3", "AC")},
+		},
+		{
+			name:   "first failure",
+			policy: FeedbackFirstFailure,
+			detail: []*JudgeDetail{detail("1", "AC"), detail("2", "TLE"), detail("3", "AC")},
+			want:   []*JudgeDetail{detail("1", "AC"), hidden("2", "TLE")},
+		},
+		{
+			name:   "first failure on example",
+			policy: FeedbackFirstFailure,
+			detail: []*JudgeDetail{detail("1", "WA"), detail("2", "AC"), detail("3", "AC")},
+			want:   []*JudgeDetail{detail("1", "WA")},
+		},
+		{
+			name:   "ignored is no failure",
+			policy: FeedbackFirstFailure,
+			detail: []*JudgeDetail{detail("1", "AC"), detail("2", "IG"), detail("3", "RE")},
+			want:   []*JudgeDetail{detail("1", "AC"), hidden("2", "IG"), hidden("3", "RE")},
+		},
+		{
+			name:   "compile detail is kept",
+			policy: FeedbackFirstFailure,
+			detail: []*JudgeDetail{detail("1", "WA"), detail("2", "AC"), detail("3", "AC"), detail("compile", "AC")},
+			want:   []*JudgeDetail{detail("1", "WA"), detail("compile", "AC")},
+		},
+	}
+	for _, tt := range tests {
+		j := &JudgeResult{Verdict: "WA", Detail: tt.detail, Log: NewPCILog("judge")}
+		j.applyFeedback(tt.policy, cases)
+		if !reflect.DeepEqual(j.Detail, tt.want) {
+			t.Errorf("%s: got %s, want %s", tt.name, details(j.Detail), details(tt.want))
+		}
+		full := j.Unredacted()
+		if !reflect.DeepEqual(full.Detail, tt.detail) || full.Log == nil {
+			t.Errorf("%s: unredacted result was changed", tt.name)
+		}
+		if tt.policy != FeedbackFull && j.Log != nil {
+			t.Errorf("%s: log was not hidden", tt.name)
+		}
+	}
+}
+
+func details(d []*JudgeDetail) string {
+	ret := make([]string, 0, len(d))
+	for _, detail := range d {
+		ret = append(ret, detail.Name+":"+detail.Verdict+":"+detail.Input)
+	}
+	return strings.Join(ret, " ")
+}
+
+type recordSink []*JudgeEvent
+
+func (s *recordSink) Emit(ev *JudgeEvent) {
+	*s = append(*s, ev)
+}
+
+func TestFirstFailureSink(t *testing.T) {
+	finished := func(test int, verdict string) *JudgeEvent {
+		return &JudgeEvent{Type: EventTestFinished, Test: test, Total: 4, Detail: &JudgeDetail{Verdict: verdict}}
+	}
+	rec := &recordSink{}
+	sink := newFirstFailureSink(rec)
+	// tests 2 and 4 run first, test 3 fails
+	for _, ev := range []*JudgeEvent{
+		{Type: EventCompileFinished},
+		{Type: EventTestStarted, Test: 1},
+		{Type: EventTestStarted, Test: 2},
+		finished(2, "AC"),
+		{Type: EventTestStarted, Test: 4},
+		finished(4, "AC"),
+		finished(1, "AC"),
+		{Type: EventTestStarted, Test: 3},
+		finished(3, "WA"),
+	} {
+		sink.Emit(ev)
+	}
+
+	want := []struct {
+		typ     EventType
+		test    int
+		judged  int
+		verdict string // empty when the detail is hidden
+	}{
+		{EventCompileFinished, 0, 0, ""},
+		{EventTestStarted, 1, 0, ""},
+		{EventTestFinished, 1, 1, "AC"},
+		{EventTestFinished, 2, 2, "AC"},
+		{EventTestStarted, 3, 0, ""},
+		{EventTestFinished, 3, 3, "WA"},
+		{EventTestFinished, 4, 4, ""},
+	}
+	if len(*rec) != len(want) {
+		t.Fatalf("got %d events, want %d", len(*rec), len(want))
+	}
+	for i, ev := range *rec {
+		verdict := ""
+		if ev.Detail != nil {
+			verdict = ev.Detail.Verdict
+		}
+		w := want[i]
+		if ev.Type != w.typ || ev.Test != w.test || ev.Judged != w.judged || verdict != w.verdict {
+			t.Errorf("event %d: got %s of test %d judged %d verdict %q, want %s of test %d judged %d verdict %q",
+				i, ev.Type, ev.Test, ev.Judged, verdict, w.typ, w.test, w.judged, w.verdict)
+		}
+	}
+}
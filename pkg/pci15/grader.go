@@ -0,0 +1,69 @@
+package pci15
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	shutil "github.com/termie/go-shutil"
+)
+
+// Grader are the files of a problem compiled with submissions in a language,
+// like the grader of function-interactive problems. Paths are relative to the
+// problem, files are copied next to the submission under their base name.
+type Grader struct {
+	Files   []string `json:"files"`             // compiled with the submission
+	Headers []string `json:"headers,omitempty"` // only copied
+	// Variables override the variables of the language once the submission
+	// is named, e.g. mainclass to run the class of a Java grader
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// graderFor returns the grader for lang, or for the family of lang, cpp for
+// cpp.gxx11. It is nil when the problem has no graders, and an error when it
+// has none for lang.
+func (p *ProblemConfig) graderFor(lang string) (*Grader, error) {
+	if len(p.Grader) == 0 {
+		return nil, nil
+	}
+	if g, ok := p.Grader[lang]; ok {
+		return g, nil
+	}
+	if i := strings.Index(lang, "."); i >= 0 {
+		if g, ok := p.Grader[lang[:i]]; ok {
+			return g, nil
+		}
+	}
+	return nil, fmt.Errorf("the problem has no grader for %s", lang)
+}
+
+// copyTo copies the files of g from problem to workDir, and returns the paths
+// of those compiled with the submission.
+func (g *Grader) copyTo(problem, workDir string) ([]string, error) {
+	var sources []string
+	for _, files := range [][]string{g.Headers, g.Files} {
+		for _, file := range files {
+			dst := filepath.Join(workDir, filepath.Base(file))
+			if _, err := shutil.Copy(filepath.Join(problem, file), dst, false); err != nil {
+				return nil, fmt.Errorf("failed to copy grader file %s: %v", file, err)
+			}
+		}
+	}
+	for _, file := range g.Files {
+		sources = append(sources, filepath.Join(workDir, filepath.Base(file)))
+	}
+	return sources, nil
+}
+
+// expandSources replaces the {sources} argument of cmd by sources.
+func expandSources(cmd []string, sources []string) []string {
+	ret := make([]string, 0, len(cmd)+len(sources))
+	for _, arg := range cmd {
+		if arg == "{sources}" {
+			ret = append(ret, sources...)
+		} else {
+			ret = append(ret, strings.Replace(arg, "{sources}", strings.Join(sources, " "), -1))
+		}
+	}
+	return ret
+}
@@ -0,0 +1,114 @@
+package pci15
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGraderFor(t *testing.T) {
+	cpp := &Grader{Files: []string{"grader/grader.cpp"}}
+	gxx11 := &Grader{Files: []string{"grader/grader11.cpp"}}
+	java := &Grader{Files: []string{"grader/Grader.java"}, Variables: map[string]string{"mainclass": "Grader"}}
+	p := &ProblemConfig{Grader: map[string]*Grader{"cpp": cpp, "cpp.gxx11": gxx11, "java": java}}
+	tests := []struct {
+		problem *ProblemConfig
+		lang    string
+		want    *Grader
+		ok      bool
+	}{
+		{p, "cpp", cpp, true},
+		{p, "cpp.gxx11", gxx11, true},
+		{p, "cpp.gxx14", cpp, true},
+		{p, "java.8", java, true},
+		{p, "c", nil, false},
+		{p, "c.gcc", nil, false},
+		{&ProblemConfig{}, "c", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.problem.graderFor(tt.lang)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("%s: got %v, %v, want %v", tt.lang, got, err, tt.want)
+		}
+	}
+}
+
+func TestExpandSources(t *testing.T) {
+	sources := []string{"/w/main.cpp", "/w/grader.cpp"}
+	tests := []struct {
+		name    string
+		cmd     []string
+		sources []string
+		want    []string
+	}{
+		{
+			name:    "standalone",
+			cmd:     []string{"g++", "-O2", "{sources}", "-o", "main"},
+			sources: sources,
+			want:    []string{"g++", "-O2", "/w/main.cpp", "/w/grader.cpp", "-o", "main"},
+		},
+		{
+			name:    "embedded",
+			cmd:     []string{"sh", "-c", "javac {sources} && jar cf main.jar *.class"},
+			sources: sources,
+			want:    []string{"sh", "-c", "javac /w/main.cpp /w/grader.cpp && jar cf main.jar *.class"},
+		},
+		{
+			name:    "single source",
+			cmd:     []string{"gcc", "{sources}"},
+			sources: []string{"/w/main.c"},
+			want:    []string{"gcc", "/w/main.c"},
+		},
+		{
+			name:    "no argument",
+			cmd:     []string{"fpc", "main.pas"},
+			sources: sources,
+			want:    []string{"fpc", "main.pas"},
+		},
+	}
+	for _, tt := range tests {
+		if got := expandSources(tt.cmd, tt.sources); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGraderCopyTo(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "grader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	problem, workDir := filepath.Join(tmp, "problem"), filepath.Join(tmp, "work")
+	for _, dir := range []string{filepath.Join(problem, "grader"), workDir} {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"grader/grader.cpp", "grader/grader.h"} {
+		if err := ioutil.WriteFile(filepath.Join(problem, f), []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g := &Grader{Files: []string{"grader/grader.cpp"}, Headers: []string{"grader/grader.h"}}
+	sources, err := g.copyTo(problem, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(workDir, "grader.cpp")}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("got sources %v, want %v", sources, want)
+	}
+	for _, f := range []string{"grader.cpp", "grader.h"} {
+		if _, err := os.Stat(filepath.Join(workDir, f)); err != nil {
+			t.Errorf("%s was not copied: %v", f, err)
+		}
+	}
+
+	missing := &Grader{Files: []string{"grader/missing.cpp"}}
+	if _, err := missing.copyTo(problem, workDir); err == nil {
+		t.Errorf("missing grader file was copied")
+	}
+}
@@ -0,0 +1,204 @@
+package pci15
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/trace"
+	"github.com/sirupsen/logrus"
+	shutil "github.com/termie/go-shutil"
+)
+
+type HackStatus string
+
+const (
+	HackSuccessful   HackStatus = "successful"
+	HackUnsuccessful HackStatus = "unsuccessful"
+	HackInvalidInput HackStatus = "invalid_input"
+)
+
+type HackResult struct {
+	Status HackStatus `json:"status"`
+	// Comment is the message of the validator for invalid input, and of the
+	// checker otherwise
+	Comment string       `json:"comment,omitempty"`
+	Verdict string       `json:"verdict,omitempty"`
+	Detail  *JudgeDetail `json:"detail,omitempty"`
+	Result  *JudgeResult `json:"result,omitempty"`
+	Log     *PCILog      `json:"log,omitempty"`
+}
+
+// Hack runs code on input given by a challenger. input is checked by the
+// validator of problem, the answer is generated by its main_ac, then code is
+// judged on it with the limits and checker of the problem. The hack is
+// successful when code fails the test, see hackStatus.
+func Hack(ctx context.Context, conf *Config, code *SourceCode, problem, input string) (*HackResult, error) {
+	ctx, span := trace.Start(ctx, "hack")
+	defer span.Finish()
+
+	log := NewPCILog("hack")
+	result := &HackResult{Log: log}
+
+	problemConf := &ProblemConfig{}
+	if err := loadYAML(filepath.Join(problem, "problem.yaml"), problemConf); err != nil {
+		return nil, fmt.Errorf("failed to load problem.yaml: %v", err)
+	}
+	if problemConf.Validator == nil {
+		return nil, errors.New("problem has no validator")
+	}
+	if problemConf.AnswerGenerator == nil {
+		return nil, errors.New("problem has no main_ac")
+	}
+	if problemConf.Interactor != nil {
+		return nil, errors.New("hacking interactive problems is not supported")
+	}
+	if problemConf.Type == ProblemTypeOutputOnly {
+		return nil, errors.New("hacking output-only problems is not supported")
+	}
+	if problemConf.Type == ProblemTypeCommunication {
+		return nil, errors.New("hacking communication problems is not supported")
+	}
+
+	tmpDir, err := filepath.Abs(conf.Tmp)
+	if err != nil {
+		return nil, err
+	}
+	workDir := filepath.Join(tmpDir, GetRandomString())
+	if err := os.MkdirAll(workDir, 0777); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	validatorCmd := problemCommand(conf, problem, "validator", problemConf.Validator, log)
+	validatorStderr := filepath.Join(workDir, "validator.stderr")
+	_, validateSpan := trace.Start(ctx, "validate")
+	validatorResult, err := Execute(ctx, validatorCmd, 10., 512*1024*1024, 1., "", workDir, false, input, "-", validatorStderr)
+	validateSpan.SetError(err)
+	validateSpan.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run validator: %v", err)
+	}
+	validatorOutput, _ := ReadFirstBytes(validatorStderr, 1024)
+	log.Entry(LevelInfo, "Validated", LogFields{
+		"exitcode": validatorResult.ExitCode,
+		"exceeded": validatorResult.ExitReason,
+		"output":   validatorOutput,
+	})
+	if validatorResult.ExitReason != "none" || validatorResult.ExitCode != 0 {
+		result.Status = HackInvalidInput
+		result.Comment = validatorOutput
+		return result, nil
+	}
+
+	answer := filepath.Join(workDir, "answer")
+	answerStderr := filepath.Join(workDir, "main_ac.stderr")
+	generatorCmd := problemCommand(conf, problem, "main_ac", problemConf.AnswerGenerator, log)
+	_, generateSpan := trace.Start(ctx, "generate_answer")
+	generatorResult, err := Execute(ctx, generatorCmd, 10., problemConf.MemoryLimit*1024*1024, 1., "", workDir, false, input, answer, answerStderr)
+	generateSpan.SetError(err)
+	generateSpan.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run main_ac: %v", err)
+	}
+	if generatorResult.ExitReason != "none" || generatorResult.ExitCode != 0 {
+		generatorOutput, _ := ReadFirstBytes(answerStderr, 1024)
+		log.Entry(LevelError, "main_ac failed", LogFields{
+			"exitcode": generatorResult.ExitCode,
+			"exceeded": generatorResult.ExitReason,
+			"output":   generatorOutput,
+		})
+		return nil, fmt.Errorf("main_ac failed on the input: %s", generatorResult.ExitReason)
+	}
+
+	hackProblem, err := hackProblemDir(problem, filepath.Join(workDir, "problem"), problemConf, input, answer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare hack test: %v", err)
+	}
+
+	judgeConf := *conf
+	judgeConf.Problem = hackProblem
+	judgeConf.RunAll = false
+	judgeConf.Feedback = FeedbackFull
+	judgeResult, err := Judge(ctx, &judgeConf, code, hackProblem)
+	if err != nil {
+		return nil, err
+	}
+	result.Result = judgeResult
+	result.Verdict = judgeResult.Verdict
+	if len(judgeResult.Detail) > 0 && judgeResult.Detail[0].Name != "compile" {
+		result.Detail = judgeResult.Detail[0]
+		result.Comment = result.Detail.Comment
+	}
+	if result.Status, err = hackStatus(judgeResult.Verdict); err != nil {
+		return nil, err
+	}
+	logrus.Infof("Hack %s: %s", result.Status, result.Verdict)
+	span.SetAttr("status", string(result.Status))
+	return result, nil
+}
+
+// hackStatus tells whether a hack judged verdict is successful. Only verdicts
+// caused by the code failing the test are, compile errors are not the merit
+// of the input, and system errors mean the hack was not judged at all.
+func hackStatus(verdict string) (HackStatus, error) {
+	switch verdict {
+	case "WA", "TLE", "MLE", "RE", "OLE", "ILE":
+		return HackSuccessful, nil
+	case "AC", "CE":
+		return HackUnsuccessful, nil
+	}
+	return "", fmt.Errorf("failed to judge the hack: %s", verdict)
+}
+
+// hackProblemDir makes dir a copy of problem, linking its files, whose only
+// test case is input with answer.
+func hackProblemDir(problem, dir string, problemConf *ProblemConfig, input, answer string) (string, error) {
+	problem, err := filepath.Abs(problem)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+	entries, err := ioutil.ReadDir(problem)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.Name() == "problem.yaml" {
+			continue
+		}
+		if err := os.Symlink(filepath.Join(problem, entry.Name()), filepath.Join(dir, entry.Name())); err != nil {
+			return "", err
+		}
+	}
+
+	name := "hack-" + GetRandomString()
+	if err := shutil.CopyFile(input, filepath.Join(dir, name+".in"), false); err != nil {
+		return "", err
+	}
+	if err := os.Rename(answer, filepath.Join(dir, name+".ans")); err != nil {
+		return "", err
+	}
+
+	hackConf := *problemConf
+	hackConf.TestSolutions = nil
+	hackConf.Case = []TestCase{{
+		Input:  name + ".in",
+		Output: name + ".ans",
+		Score:  1,
+	}}
+	data, err := json.Marshal(&hackConf)
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "problem.yaml"), data, 0644); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
@@ -0,0 +1,29 @@
+package pci15
+
+import "testing"
+
+func TestHackStatus(t *testing.T) {
+	tests := []struct {
+		verdict string
+		status  HackStatus
+		err     bool
+	}{
+		{"WA", HackSuccessful, false},
+		{"TLE", HackSuccessful, false},
+		{"MLE", HackSuccessful, false},
+		{"RE", HackSuccessful, false},
+		{"OLE", HackSuccessful, false},
+		{"ILE", HackSuccessful, false},
+		{"AC", HackUnsuccessful, false},
+		{"CE", HackUnsuccessful, false},
+		{"SE", "", true},
+		{"IG", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		status, err := hackStatus(tt.verdict)
+		if status != tt.status || (err != nil) != tt.err {
+			t.Errorf("hackStatus(%q) = %q, %v, want %q, error %v", tt.verdict, status, err, tt.status, tt.err)
+		}
+	}
+}
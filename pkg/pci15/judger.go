@@ -1,16 +1,19 @@
 package pci15
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 
+	"github.com/erjiaqing/PCIJudger2/pkg/artifact"
 	"github.com/erjiaqing/PCIJudger2/pkg/builtin_cmp"
+	"github.com/erjiaqing/PCIJudger2/pkg/metrics"
+	"github.com/erjiaqing/PCIJudger2/pkg/trace"
 	"github.com/sirupsen/logrus"
 	shutil "github.com/termie/go-shutil"
 )
@@ -25,10 +28,16 @@ type JudgeResult struct {
 	Score       int            `json:"score"`
 	FullScore   int            `json:"full_score"`
 	Detail      []*JudgeDetail `json:"detail"`
+	Log         *PCILog        `json:"log,omitempty"`
+	Trace       []*trace.Span  `json:"trace,omitempty"`
+	Files       []string       `json:"files,omitempty"` // unpacked from an archive submission
 	lastTest    int
 	judgeResult map[int]*JudgeDetail
 	judgeState  *sync.Map
 	testRun     bool // In test run, we will always run all test cases
+	artifacts   *artifact.Store
+	keep        artifact.Policy
+	full        *JudgeResult // before applying the feedback policy
 }
 
 type JudgeDetail struct {
@@ -43,6 +52,8 @@ type JudgeDetail struct {
 	ExeMemory  uint64  `json:"exe_memory"`
 	ExitCode   int32   `json:"exit_code"`
 	ExitSignal int32   `json:"exit_signal"`
+
+	Artifacts []*artifact.Artifact `json:"artifacts,omitempty"`
 }
 
 type JudgeRequest struct {
@@ -114,7 +125,9 @@ func (j *JudgeResult) checkPass(t string) bool {
 
 func (j *JudgeResult) prepareProblemConf(problemConf *ProblemConfig) error {
 	for i, _ := range problemConf.Case {
-		if len(problemConf.Case[i].Dependencies) == 0 && i > 0 {
+		// the outputs of output-only problems are all submitted, and judged
+		// independently unless told otherwise
+		if len(problemConf.Case[i].Dependencies) == 0 && i > 0 && problemConf.Type != ProblemTypeOutputOnly {
 			problemConf.Case[i].Dependencies = append(problemConf.Case[i].Dependencies, problemConf.Case[i-1].Input)
 		} else {
 			for _, p := range problemConf.Case[i].Dependencies {
@@ -166,11 +179,14 @@ func fastMode(problemPath string, problemConf *ProblemConfig) error {
 	return nil
 }
 
-func (j *JudgeResult) doJudge(testId int, testInfo TestCase, problemConf *ProblemConfig, execCommand *ExecuteCommand, timeLimit float32, codeLanguage *Language, chrootName, workdir, problem string, checkerCmd, interCmd []string) (*JudgeDetail, bool) {
+func (j *JudgeResult) doJudge(ctx context.Context, events EventSink, log *PCILog, testId int, testInfo TestCase, problemConf *ProblemConfig, execCommand *ExecuteCommand, timeLimit float32, codeLanguage *Language, chrootName, workdir, problem string, checkerCmd, interCmd, managerCmd []string) (*JudgeDetail, bool) {
 	logrus.Infof("Judging test %d", testId+1)
 
 	judgeUid := GetRandomString()
 	checkPoint := false
+	stdoutFile := filepath.Join(workdir, judgeUid+".stdout")
+	stderrFile := filepath.Join(workdir, judgeUid+".stderr")
+	checkerStderrFile := filepath.Join(workdir, judgeUid+".checker.stderr")
 
 	input := testInfo.Input
 
@@ -179,6 +195,21 @@ func (j *JudgeResult) doJudge(testId int, testInfo TestCase, problemConf *Proble
 		Verdict: "AC",
 	}
 
+	outputs := []testOutput{
+		{"stdout", stdoutFile},
+		{"stderr", stderrFile},
+		{"checker_stderr", checkerStderrFile},
+	}
+	transcriptFile := ""
+	if j.artifacts != nil && problemConf.Interactor != nil {
+		transcriptFile = filepath.Join(workdir, judgeUid+".transcript")
+		outputs = append(outputs, testOutput{"interactor_transcript", transcriptFile})
+	}
+	if problemConf.Type == ProblemTypeCommunication {
+		outputs = append(outputs, problemConf.Communication.outputs(workdir, judgeUid)...)
+	}
+	defer j.saveOutputs(resDetail, log, outputs)
+
 	if input[0] == '*' {
 		checkPoint = true
 		resDetail.Name = fmt.Sprintf("#%d (Checkpoint)", testId+1)
@@ -188,6 +219,7 @@ func (j *JudgeResult) doJudge(testId int, testInfo TestCase, problemConf *Proble
 
 	for _, dep := range testInfo.Dependencies {
 		if !j.testRun && !j.checkPass(dep) {
+			log.Entry(LevelInfo, "Skipped, dependency not passed", LogFields{"dependency": dep})
 			resDetail.Verdict = "IG"
 			resDetail.Score = 0
 			return resDetail, false
@@ -198,24 +230,64 @@ func (j *JudgeResult) doJudge(testId int, testInfo TestCase, problemConf *Proble
 		return resDetail, true
 	}
 
+	events.Emit(&JudgeEvent{
+		Type:  EventTestStarted,
+		Test:  testId + 1,
+		Total: len(problemConf.Case),
+	})
+
 	var execResult, interactorResult *ExecuteResult
 	var err error
-	if problemConf.Interactor == nil {
-		execResult, err = Execute(execCommand.Execute, timeLimit, problemConf.MemoryLimit*1024*1024, codeLanguage.Execute.TimeRatio, filepath.Join("/fj_tmp/mirrorfs", chrootName), workdir, true, filepath.Join(problem, testInfo.Input), judgeUid+".stdout", judgeUid+".stderr")
+	if problemConf.Type == ProblemTypeOutputOnly {
+		submitted, err := j.submittedOutput(workdir, &testInfo, stdoutFile)
 		if err != nil {
+			resDetail.Verdict = "SE"
+			resDetail.Comment = fmt.Sprintf("Failed to copy output: %v", err)
+			log.Entry(LevelError, "Failed to copy output", LogFields{"error": err.Error()})
+			return resDetail, false
+		}
+		if !submitted {
+			resDetail.Verdict = "WA"
+			resDetail.Comment = "no output"
+			log.Entry(LevelInfo, "No output submitted", LogFields{"output": testInfo.outputName()})
+			return resDetail, false
+		}
+		// the output is checked as if a program printed it
+		execResult = &ExecuteResult{ExitReason: "none"}
+	} else if problemConf.Type == ProblemTypeCommunication {
+		execResult, resDetail.Comment, err = problemConf.Communication.communicate(ctx, log, execCommand.Execute, managerCmd, timeLimit, problemConf.MemoryLimit, codeLanguage.Execute.TimeRatio, filepath.Join("/fj_tmp/mirrorfs", chrootName), workdir, judgeUid, filepath.Join(problem, testInfo.Input), filepath.Join(problem, testInfo.Output), stdoutFile)
+		if err != nil {
+			metrics.SystemErrors.Inc("communication")
+			resDetail.Verdict = "SE"
+			resDetail.Comment = fmt.Sprintf("Failed to execute code: %v", err)
+			log.Entry(LevelError, "Failed to run communication", LogFields{"error": err.Error()})
+			return resDetail, false
+		}
+	} else if problemConf.Interactor == nil {
+		execResult, err = Execute(ctx, execCommand.Execute, timeLimit, problemConf.MemoryLimit*1024*1024, codeLanguage.Execute.TimeRatio, filepath.Join("/fj_tmp/mirrorfs", chrootName), workdir, true, filepath.Join(problem, testInfo.Input), stdoutFile, stderrFile)
+		if err != nil {
+			metrics.SystemErrors.Inc("execute")
 			resDetail.Verdict = "SE"
 			resDetail.Comment = fmt.Sprintf("Failed to execute code: %v", err)
-			stderr, _ := ReadFirstBytes(judgeUid+".stderr", 1024)
+			stderr, _ := ReadFirstBytes(stderrFile, 1024)
 			logrus.Errorf("Datail: %s", stderr)
+			log.Entry(LevelError, "Failed to execute code", LogFields{"error": err.Error(), "stderr": stderr})
 			return resDetail, false
 		}
 	} else {
-		execResult, interactorResult, err = ExecuteInteractor(execCommand.Execute, append(interCmd, filepath.Join(problem, testInfo.Input), judgeUid+".stdout", filepath.Join(problem, testInfo.Output)), timeLimit, problemConf.MemoryLimit*1024*1024, codeLanguage.Execute.TimeRatio, filepath.Join("/fj_tmp/mirrorfs", chrootName), workdir, true)
+		execResult, interactorResult, err = ExecuteInteractor(ctx, execCommand.Execute, append(interCmd[:len(interCmd):len(interCmd)], filepath.Join(problem, testInfo.Input), stdoutFile, filepath.Join(problem, testInfo.Output)), timeLimit, problemConf.MemoryLimit*1024*1024, codeLanguage.Execute.TimeRatio, filepath.Join("/fj_tmp/mirrorfs", chrootName), workdir, workdir, true, transcriptFile)
 		if err != nil {
+			metrics.SystemErrors.Inc("interactor")
 			resDetail.Verdict = "SE"
 			resDetail.Comment = fmt.Sprintf("Failed to execute code: %v", err)
+			log.Entry(LevelError, "Failed to execute code with interactor", LogFields{"error": err.Error()})
 			return resDetail, false
 		}
+		log.Entry(LevelDebug, "Interactor finished", LogFields{
+			"cputime":  interactorResult.CPUTime,
+			"exitcode": interactorResult.ExitCode,
+			"exceeded": interactorResult.ExitReason,
+		})
 		if execResult.ExitReason == "none" {
 			if interactorResult.ExitReason != "none" {
 				execResult.ExitReason = "WA"
@@ -227,9 +299,20 @@ func (j *JudgeResult) doJudge(testId int, testInfo TestCase, problemConf *Proble
 
 	resDetail.ExeTime = execResult.CPUTime
 	resDetail.ExeMemory = execResult.ExeMemory / 1024
+	if problemConf.Type != ProblemTypeOutputOnly {
+		log.Entry(LevelInfo, "Execution finished", LogFields{
+			"cputime":  execResult.CPUTime,
+			"realtime": execResult.RealTime,
+			"memory":   execResult.ExeMemory,
+			"exitcode": execResult.ExitCode,
+			"exitsig":  execResult.ExitSignal,
+			"termsig":  execResult.TermSignal,
+			"exceeded": execResult.ExitReason,
+		})
+	}
 
 	resDetail.Input, _ = ReadFirstBytes(filepath.Join(problem, testInfo.Input), 128)
-	resDetail.Output, _ = ReadFirstBytes(judgeUid+".stdout", 128)
+	resDetail.Output, _ = ReadFirstBytes(stdoutFile, 128)
 	resDetail.Answer, _ = ReadFirstBytes(filepath.Join(problem, testInfo.Output), 128)
 
 	if execResult.ExitReason != "none" {
@@ -245,29 +328,52 @@ func (j *JudgeResult) doJudge(testId int, testInfo TestCase, problemConf *Proble
 		return resDetail, false
 	}
 
+	events.Emit(&JudgeEvent{
+		Type:  EventCheckStarted,
+		Test:  testId + 1,
+		Total: len(problemConf.Case),
+	})
+
 	if problemConf.Checker.Source[0] == '!' {
-		checkerRes, err := builtin_cmp.Diff[problemConf.Checker.Source](judgeUid+".stdout", filepath.Join(problem, testInfo.Output))
+		_, checkSpan := trace.Start(ctx, "check")
+		checkSpan.SetAttr("checker", problemConf.Checker.Source)
+		defer checkSpan.Finish()
+		checkerRes, err := builtin_cmp.Diff[problemConf.Checker.Source](stdoutFile, filepath.Join(problem, testInfo.Output))
 		if err != nil {
+			metrics.SystemErrors.Inc("builtin_checker")
 			resDetail.Verdict = "SE"
 			resDetail.Comment = err.Error()
+			log.Entry(LevelError, "Builtin checker failed", LogFields{"checker": problemConf.Checker.Source, "error": err.Error()})
 		} else if !checkerRes {
 			resDetail.Verdict = "WA"
 		} else {
 			resDetail.Score = testInfo.Score
 		}
+		log.Entry(LevelInfo, "Checked", LogFields{"checker": problemConf.Checker.Source, "verdict": resDetail.Verdict})
 		return resDetail, checkerRes
 	}
 
-	tcheckerCmd := append(checkerCmd, filepath.Join(problem, testInfo.Input), judgeUid+".stdout", filepath.Join(problem, testInfo.Output))
+	tcheckerCmd := append(checkerCmd[:len(checkerCmd):len(checkerCmd)], filepath.Join(problem, testInfo.Input), stdoutFile, filepath.Join(problem, testInfo.Output))
 
-	checkerResult, err := Execute(tcheckerCmd, 10., problemConf.MemoryLimit*1024*1024, 1., "", "-", false, "-", judgeUid+".checker.stderr", judgeUid+".checker.stderr")
-	resDetail.Comment, _ = ReadFirstBytes(judgeUid+".checker.stderr", 128)
+	checkCtx, checkSpan := trace.Start(ctx, "check")
+	defer checkSpan.Finish()
+	checkerResult, err := Execute(checkCtx, tcheckerCmd, 10., problemConf.MemoryLimit*1024*1024, 1., "", workdir, false, "-", checkerStderrFile, checkerStderrFile)
+	resDetail.Comment, _ = ReadFirstBytes(checkerStderrFile, 128)
 
 	if err != nil {
+		metrics.SystemErrors.Inc("checker")
 		resDetail.Verdict = "SE"
 		resDetail.Comment = fmt.Sprintf("Failed to run checker: %v", err)
+		log.Entry(LevelError, "Failed to run checker", LogFields{"checker": tcheckerCmd, "error": err.Error()})
 		return resDetail, false
-	} else if checkerResult.ExitCode != 0 {
+	}
+	log.Entry(LevelInfo, "Checked", LogFields{
+		"checker":  tcheckerCmd,
+		"exitcode": checkerResult.ExitCode,
+		"exceeded": checkerResult.ExitReason,
+		"comment":  resDetail.Comment,
+	})
+	if checkerResult.ExitCode != 0 {
 		resDetail.Verdict = "WA"
 		return resDetail, false
 	}
@@ -276,9 +382,140 @@ func (j *JudgeResult) doJudge(testId int, testInfo TestCase, problemConf *Proble
 	return resDetail, true
 }
 
-func Judge(conf *Config, code *SourceCode, problem string) (*JudgeResult, error) {
+// problemCommand returns the command running program of the problem in
+// directory problem, as compiled by BuildProblem. name is used in logs.
+func problemCommand(conf *Config, problem, name string, program *SourceCode, log *PCILog) []string {
+	if program.Executable != "" {
+		return []string{filepath.Join(problem, program.Executable)}
+	}
+	program.Source = filepath.Join(problem, program.Source)
+	exec, _, err := GetExecuteCommand(program, conf)
+	if err != nil {
+		logrus.Warnf("Failed to get %s Exec: %v", name, err)
+		log.Warnf("Failed to get %s command, falling back to %s.exe: %v", name, program.Source, err)
+		return []string{program.Source + ".exe"}
+	}
+	return exec.Execute
+}
+
+// runChecker checks output against answer with a builtin checker, or with
+// checkerCmd when checker is not one, returning the verdict and the comment
+// of the checker.
+func runChecker(ctx context.Context, checker string, checkerCmd []string, memoryLimit uint64, workDir, input, output, answer string) (string, string, error) {
+	if checker[0] == '!' {
+		same, err := builtin_cmp.Diff[checker](output, answer)
+		if err != nil {
+			return "", "", fmt.Errorf("builtin checker failed: %v", err)
+		}
+		if !same {
+			return "WA", "", nil
+		}
+		return "AC", "", nil
+	}
+	stderr := filepath.Join(workDir, "checker.stderr")
+	cmd := append(checkerCmd[:len(checkerCmd):len(checkerCmd)], input, output, answer)
+	res, err := Execute(ctx, cmd, 10., memoryLimit*1024*1024, 1., "", workDir, false, "-", stderr, stderr)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to run checker: %v", err)
+	}
+	comment, _ := ReadFirstBytes(stderr, 128)
+	if res.ExitReason != "none" && res.ExitReason != "RE" {
+		return "", "", fmt.Errorf("checker failed: %s", res.ExitReason)
+	}
+	// checkers reject the output by exiting with non-zero
+	if res.ExitCode != 0 {
+		return "WA", comment, nil
+	}
+	return "AC", comment, nil
+}
+
+type testOutput struct {
+	name string
+	path string
+}
+
+// saveOutputs keeps the outputs of a test in the artifact store as the policy
+// asks, and removes them from the working directory.
+func (j *JudgeResult) saveOutputs(detail *JudgeDetail, log *PCILog, outputs []testOutput) {
+	keep := j.artifacts != nil && j.keep.Keep(detail.Verdict)
+	for _, out := range outputs {
+		if keep {
+			a, err := j.artifacts.Put(out.name, out.path)
+			if err == nil {
+				detail.Artifacts = append(detail.Artifacts, a)
+			} else if !os.IsNotExist(err) {
+				log.Entry(LevelWarn, "Failed to keep artifact", LogFields{"name": out.name, "error": err.Error()})
+			}
+		}
+		os.Remove(out.path)
+	}
+}
+
+// Judge compiles code and runs it against every test case of problem. It does
+// not touch the working directory of the process, so several judgements may
+// run at the same time. Cancelling ctx kills running sandboxes, tears down
+// mirrorfs and makes Judge return ctx.Err().
+//
+// Progress is reported to conf.Events and conf.HostSocket, the last event is
+// always judge_finished, carrying either the result or the error.
+//
+// Phases are traced into the trace of ctx, when conf.Trace is set a trace is
+// started if ctx has none and embedded in the result.
+func Judge(ctx context.Context, conf *Config, code *SourceCode, problem string) (*JudgeResult, error) {
+	tr := trace.FromContext(ctx)
+	if tr == nil && conf.Trace {
+		tr = trace.New()
+		ctx = trace.NewContext(ctx, tr)
+	}
+	ctx, span := trace.Start(ctx, "judge")
+	span.SetAttr("lang", code.Language)
+	span.SetAttr("problem", problem)
+
+	events := conf.eventSink()
+	events.Emit(&JudgeEvent{Type: EventJudgeStarted})
+	judgeResult, err := judge(ctx, conf, code, problem, events)
+	if judgeResult != nil {
+		span.SetAttr("verdict", judgeResult.Verdict)
+	}
+	span.SetError(err)
+	span.Finish()
+	if conf.Trace && judgeResult != nil {
+		judgeResult.Unredacted().Trace = tr.Spans()
+	}
+	if err == nil {
+		metrics.Judgements.Inc(judgeResult.Verdict, metricsLanguage(conf, code))
+	} else if ctx.Err() == nil {
+		metrics.Judgements.Inc("SE", metricsLanguage(conf, code))
+		metrics.SystemErrors.Inc("judge")
+	}
+	finished := &JudgeEvent{
+		Type:   EventJudgeFinished,
+		Result: judgeResult,
+	}
+	if err != nil {
+		finished.Error = err.Error()
+	}
+	events.Emit(finished)
+	return judgeResult, err
+}
+
+// metricsLanguage is the language of code as a metrics label, unknown unless
+// its language file loads, so that submissions can not create new series.
+func metricsLanguage(conf *Config, code *SourceCode) string {
+	if code.Language == "" || strings.ContainsAny(code.Language, "/\\") {
+		return "unknown"
+	}
+	if err := loadYAML(filepath.Join(conf.LanguageStorage, code.Language+".yaml"), &Language{}); err != nil {
+		return "unknown"
+	}
+	return code.Language
+}
+
+func judge(ctx context.Context, conf *Config, code *SourceCode, problem string, events EventSink) (*JudgeResult, error) {
+	log := NewPCILog("judge")
 	judgeResult := &JudgeResult{
 		Success:     true,
+		Log:         log,
 		judgeResult: make(map[int]*JudgeDetail),
 		judgeState:  &sync.Map{},
 		testRun:     conf.RunAll,
@@ -288,6 +525,7 @@ func Judge(conf *Config, code *SourceCode, problem string) (*JudgeResult, error)
 
 	if err := loadYAML(filepath.Join(problem, "problem.yaml"), problemConf); err != nil {
 		logrus.Warningf("Failed to find problem.yaml, enter fast mode...")
+		log.Warnf("Failed to load problem.yaml, enter fast mode: %v", err)
 		problemConf.TimeLimit = 1000
 		problemConf.MemoryLimit = 512
 		if err := fastMode(problem, problemConf); err != nil {
@@ -297,6 +535,10 @@ func Judge(conf *Config, code *SourceCode, problem string) (*JudgeResult, error)
 	}
 
 	judgeResult.prepareProblemConf(problemConf)
+	feedback := feedbackPolicy(conf, problemConf)
+	if feedback == FeedbackFirstFailure {
+		events = newFirstFailureSink(events)
+	}
 
 	if problemConf.TimeLimit == 0 {
 		problemConf.TimeLimit = problemConf.TimeLimitBK
@@ -306,6 +548,18 @@ func Judge(conf *Config, code *SourceCode, problem string) (*JudgeResult, error)
 		problemConf.TimeLimit = 1000
 	}
 
+	if conf.TimeLimit != 0 {
+		problemConf.TimeLimit = conf.TimeLimit
+	}
+
+	outputOnly := problemConf.Type == ProblemTypeOutputOnly
+	if outputOnly && problemConf.Interactor != nil {
+		return nil, fmt.Errorf("output-only problems can not have an interactor")
+	}
+	if err := problemConf.checkCommunication(); err != nil {
+		return nil, err
+	}
+
 	if problemConf.Checker == nil {
 		problemConf.Checker = &SourceCode{}
 	}
@@ -314,54 +568,26 @@ func Judge(conf *Config, code *SourceCode, problem string) (*JudgeResult, error)
 		problemConf.Checker.Source = "!diff"
 	}
 
-	workDir := filepath.Join(conf.Tmp, GetRandomString())
+	tmpDir, err := filepath.Abs(conf.Tmp)
+	if err != nil {
+		return nil, err
+	}
+	workDir := filepath.Join(tmpDir, GetRandomString())
 
 	if !conf.IsDocker {
 		if err := os.MkdirAll(workDir, 0777); err != nil {
 			return nil, err
 		}
+		defer os.RemoveAll(workDir)
 	} else {
-		workDir = conf.Tmp
+		workDir = tmpDir
 	}
-	//defer os.RemoveAll(workDir)
-
-	header := make([]byte, 0)
-	footer := make([]byte, 0)
-	codeBin := make([]byte, 0)
-
-	if problemConf.Template != "" {
-		header, _ = ioutil.ReadFile(filepath.Join(problem, problemConf.Template+".header."+code.Language))
-		footer, _ = ioutil.ReadFile(filepath.Join(problem, problemConf.Template+".footer."+code.Language))
-	}
-
-	codeBin, _ = ioutil.ReadFile(code.Source)
 
-	execCommand, codeLanguage, err := GetExecuteCommand2(code, conf, workDir, true)
+	judgeResult.artifacts, err = conf.ArtifactStore()
 	if err != nil {
-		return nil, err
-	}
-
-	if err := func() error {
-		fp, err := os.OpenFile(execCommand.Source, os.O_CREATE|os.O_TRUNC|os.O_RDWR, os.ModePerm)
-		if err != nil {
-			return err
-		}
-		defer fp.Close()
-		if _, err := fp.Write(header); err != nil {
-			return err
-		}
-		if _, err := fp.Write(codeBin); err != nil {
-			return err
-		}
-		if _, err := fp.Write(footer); err != nil {
-			return err
-		}
-		return nil
-	}(); err != nil {
-		return nil, fmt.Errorf("failed to copy source: %v", err)
-	} else {
-		code.Source = execCommand.Source
+		return nil, fmt.Errorf("failed to open artifact store: %v", err)
 	}
+	judgeResult.keep = conf.ArtifactPolicy
 
 	if err := func() error {
 		fp, err := os.OpenFile(filepath.Join(workDir, "mirrorfs.conf"), os.O_CREATE|os.O_TRUNC|os.O_RDWR, os.ModePerm)
@@ -378,22 +604,85 @@ func Judge(conf *Config, code *SourceCode, problem string) (*JudgeResult, error)
 		return nil, fmt.Errorf("failed to copy source: %v", err)
 	}
 
-	logrus.Infof("Code source name: %s", code.Source)
-
-	if currentDir, err := os.Getwd(); err != nil {
-		return nil, err
-	} else if err := os.Chdir(workDir); err != nil {
-		return nil, err
+	var execCommand *ExecuteCommand
+	var codeLanguage *Language
+	var newCode *SourceCode
+	// prepareErr is why the submission can not be compiled, reported as CE
+	var prepareErr error
+	if outputOnly {
+		newCode = &SourceCode{
+			Source:   code.Source,
+			Language: code.Language,
+		}
+		judgeResult.Files, prepareErr = unpackArchive(conf, code.Source, filepath.Join(workDir, submissionDir))
+		log.Infof("Unpacked %d outputs from the submission", len(judgeResult.Files))
+	} else if archiveFormat(code.Source) != "" {
+		execCommand, codeLanguage, newCode, judgeResult.Files, prepareErr = prepareProject(conf, code, problemConf, workDir)
+		log.Infof("Unpacked %d files from the archive", len(judgeResult.Files))
 	} else {
-		defer os.Chdir(currentDir)
-	}
+		header := make([]byte, 0)
+		footer := make([]byte, 0)
+		codeBin := make([]byte, 0)
 
-	newCode := &SourceCode{
-		Source:   execCommand.Source,
-		Language: code.Language,
-	}
+		if problemConf.Template != "" {
+			header, _ = ioutil.ReadFile(filepath.Join(problem, problemConf.Template+".header."+code.Language))
+			footer, _ = ioutil.ReadFile(filepath.Join(problem, problemConf.Template+".footer."+code.Language))
+		}
+
+		codeBin, _ = ioutil.ReadFile(code.Source)
+
+		var grader *Grader
+		grader, prepareErr = problemConf.graderFor(code.Language)
+		if grader != nil {
+			// a copy, the code of the caller is left as is
+			withGrader := *code
+			withGrader.Variables = grader.Variables
+			code = &withGrader
+		}
+
+		execCommand, codeLanguage, err = GetExecuteCommand2(code, conf, workDir, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := func() error {
+			fp, err := os.OpenFile(execCommand.Source, os.O_CREATE|os.O_TRUNC|os.O_RDWR, os.ModePerm)
+			if err != nil {
+				return err
+			}
+			defer fp.Close()
+			if _, err := fp.Write(header); err != nil {
+				return err
+			}
+			if _, err := fp.Write(codeBin); err != nil {
+				return err
+			}
+			if _, err := fp.Write(footer); err != nil {
+				return err
+			}
+			return nil
+		}(); err != nil {
+			return nil, fmt.Errorf("failed to copy source: %v", err)
+		} else {
+			code.Source = execCommand.Source
+		}
+
+		logrus.Infof("Code source name: %s", code.Source)
+
+		var graderSources []string
+		if grader != nil {
+			if graderSources, err = grader.copyTo(problem, workDir); err != nil {
+				return nil, err
+			}
+		}
 
-	conf.HostSocket.SendStatus("00", 0)
+		newCode = &SourceCode{
+			Source:    execCommand.Source,
+			Language:  code.Language,
+			Extra:     graderSources,
+			Variables: code.Variables,
+		}
+	}
 
 	for _, extraFile := range problemConf.ExtraFile {
 		if _, err := shutil.Copy(filepath.Join(problem, extraFile), filepath.Join(workDir, extraFile), false); err != nil {
@@ -401,9 +690,31 @@ func Judge(conf *Config, code *SourceCode, problem string) (*JudgeResult, error)
 		}
 	}
 
-	conf.HostSocket.SendStatus("01", 0)
+	events.Emit(&JudgeEvent{Type: EventCompileStarted})
 
-	compilerOutput, err := newCode.Compile2(conf, workDir, true)
+	compileLog := log.Child("compile", LogFields{"lang": newCode.Language})
+	var compilerOutput string
+	if prepareErr != nil {
+		err = prepareErr
+	} else if !outputOnly {
+		compileLog.Infof("Compiling %s", newCode.Source)
+		compilerOutput, err = newCode.Compile2(ctx, conf, workDir, true)
+	}
+	if newCode.CompileResult != nil {
+		compileLog.Entry(LevelInfo, "Compiler finished", LogFields{
+			"realtime": newCode.CompileResult.RealTime,
+			"memory":   newCode.CompileResult.ExeMemory,
+			"exitcode": newCode.CompileResult.ExitCode,
+			"exceeded": newCode.CompileResult.ExitReason,
+		})
+	}
+	if err != nil {
+		compileLog.Entry(LevelWarn, "Compilation failed", LogFields{"error": err.Error(), "output": compilerOutput})
+	}
+	log.Merge(compileLog)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
 	if err != nil && newCode.CompileResult != nil {
 		judgeResult.Verdict = "CE"
 		judgeResult.Detail = append(judgeResult.Detail, &JudgeDetail{
@@ -415,6 +726,10 @@ func Judge(conf *Config, code *SourceCode, problem string) (*JudgeResult, error)
 			ExitCode:   newCode.CompileResult.ExitCode,
 			ExitSignal: newCode.CompileResult.ExitSignal,
 		})
+		events.Emit(&JudgeEvent{
+			Type:   EventCompileFinished,
+			Detail: judgeResult.Detail[0],
+		})
 		return judgeResult, nil
 	} else if err != nil {
 		judgeResult.Verdict = "CE"
@@ -427,56 +742,57 @@ func Judge(conf *Config, code *SourceCode, problem string) (*JudgeResult, error)
 			ExitCode:   0,
 			ExitSignal: 0,
 		})
+		events.Emit(&JudgeEvent{
+			Type:   EventCompileFinished,
+			Detail: judgeResult.Detail[0],
+		})
 		return judgeResult, nil
 	}
 
-	conf.HostSocket.SendStatus("02", 0)
+	compileDetail := &JudgeDetail{
+		Name:    "compile",
+		Verdict: "AC",
+		Output:  compilerOutput,
+	}
+	if newCode.CompileResult != nil {
+		compileDetail.ExeTime = newCode.CompileResult.RealTime
+		compileDetail.ExeMemory = newCode.CompileResult.ExeMemory
+		compileDetail.ExitCode = newCode.CompileResult.ExitCode
+		compileDetail.ExitSignal = newCode.CompileResult.ExitSignal
+	}
+	events.Emit(&JudgeEvent{
+		Type:   EventCompileFinished,
+		Detail: compileDetail,
+	})
 
 	timeLimit := float32(problemConf.TimeLimit) / 1000.
 	if timeLimit > 120 {
 		timeLimit = 120.
 	}
 	judgeResult.Verdict = "AC"
-	chrootName := GetRandomString()
-	if err := func() error {
-		logrus.Infof("Setting up mirrorfs: /fj_tmp/mirrorfs/%s ...", chrootName)
-		chrootCmd := exec.Command("/usr/local/bin/lrun-mirrorfs", "--name", chrootName, "--setup", conf.MirrorFSConfig)
-		return chrootCmd.Run()
-	}(); err != nil {
-		return nil, err
-	} else {
-		defer func() error {
-			logrus.Infof("Tearing down mirrorfs: /fj_tmp/mirrorfs/%s ...", chrootName)
-			chrootCmd := exec.Command("/usr/local/bin/lrun-mirrorfs", "--name", chrootName, "--teardown", conf.MirrorFSConfig)
-			return chrootCmd.Run()
-		}()
-	}
-
-	checkerCmd := []string{filepath.Join(problem, problemConf.Checker.Executable)}
-	if problemConf.Checker.Executable == "" {
-		problemConf.Checker.Source = filepath.Join(problem, problemConf.Checker.Source)
-		checkerExec, _, err := GetExecuteCommand(problemConf.Checker, conf)
+	chrootName := ""
+	if !outputOnly {
+		name, teardown, err := setupMirrorFS(ctx, conf, log)
 		if err != nil {
-			logrus.Warnf("Failed to get checker Exec: %v", err)
-			checkerCmd = []string{filepath.Join(problem, problemConf.Checker.Source+".exe")}
-		} else {
-			checkerCmd = checkerExec.Execute
+			return nil, err
 		}
+		defer teardown()
+		chrootName = name
+	}
+
+	var checkerCmd []string
+	if problemConf.Checker.Source[0] != '!' {
+		checkerCmd = problemCommand(conf, problem, "checker", problemConf.Checker, log)
 	}
 
 	interCmd := []string{}
 	if problemConf.Interactor != nil {
-		interCmd = []string{filepath.Join(problem, problemConf.Interactor.Executable)}
-		if problemConf.Interactor.Executable == "" {
-			problemConf.Interactor.Source = filepath.Join(problem, problemConf.Interactor.Source)
-			interExec, _, err := GetExecuteCommand(problemConf.Interactor, conf)
-			if err != nil {
-				logrus.Warnf("Failed to get interactor Exec: %v", err)
-				interCmd = []string{filepath.Join(problem, problemConf.Interactor.Source+".exe")}
-			} else {
-				interCmd = interExec.Execute
-			}
-		}
+		interCmd = problemCommand(conf, problem, "interactor", problemConf.Interactor, log)
+	}
+
+	var managerCmd []string
+	if problemConf.Communication != nil && problemConf.Communication.Manager != nil {
+		managerCmd = problemCommand(conf, problem, "manager", problemConf.Communication.Manager, log)
 	}
 
 	judgeChan := make(chan *JudgeRequest, len(problemConf.Case))
@@ -497,43 +813,61 @@ func Judge(conf *Config, code *SourceCode, problem string) (*JudgeResult, error)
 
 	for i := 0; i < conf.MaxJudgeThread; i++ {
 		wg.Add(1)
-		go func() {
+		go func(worker int) {
 			defer wg.Done()
+			workerCtx, workerSpan := trace.Start(ctx, "worker")
+			workerSpan.SetLane(worker)
+			workerSpan.SetAttr("worker", worker)
+			defer workerSpan.Finish()
 			for {
 				mut.Lock()
 				val, ok := <-judgeChan
 				mut.Unlock()
-				if !ok {
+				if !ok || ctx.Err() != nil {
 					break
 				}
 
-				detail, _ := judgeResult.doJudge(val.Id, val.Case, problemConf, execCommand, timeLimit, codeLanguage, chrootName, workDir, problem, checkerCmd, interCmd)
+				testLog := log.Child(fmt.Sprintf("test#%d", val.Id+1), LogFields{"test": val.Id + 1})
+				testCtx, testSpan := trace.Start(workerCtx, "test")
+				testSpan.SetAttr("test", val.Id+1)
+				detail, _ := judgeResult.doJudge(testCtx, events, testLog, val.Id, val.Case, problemConf, execCommand, timeLimit, codeLanguage, chrootName, workDir, problem, checkerCmd, interCmd, managerCmd)
+				if detail.Verdict != "IG" && val.Case.Input[0] != '*' && !outputOnly {
+					metrics.TestCPUSeconds.Observe(float64(detail.ExeTime), newCode.Language)
+				}
+
+				testSpan.SetAttr("verdict", detail.Verdict)
+				testSpan.Finish()
+				testLog.Entry(LevelInfo, "Judged", LogFields{"verdict": detail.Verdict, "score": detail.Score})
+				log.Merge(testLog)
 
 				mut.Lock()
 
 				judgeResult.judgeState.Store(val.Case.Input, detail.Verdict)
 				judged := judgeResult.Append(val.Id, detail) + 1
 
-				conf.HostSocket.SendStatus("10", 100*judged/countTestCase)
+				events.Emit(&JudgeEvent{
+					Type:   EventTestFinished,
+					Test:   val.Id + 1,
+					Judged: judged,
+					Total:  countTestCase,
+					Detail: redactDetail(feedback, &problemConf.Case[val.Id], detail),
+				})
 				logrus.Infof("%d / %d Test Judged", judged, countTestCase)
 				mut.Unlock()
 			}
-		}()
+		}(i + 1)
 	}
 	wg.Wait()
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	judgeResult.Collect(problemConf, countTestCase)
 
 	if newCode.CompileResult != nil {
-		judgeResult.Detail = append(judgeResult.Detail, &JudgeDetail{
-			Name:       "compile",
-			Verdict:    "AC",
-			Output:     compilerOutput,
-			ExeTime:    newCode.CompileResult.RealTime,
-			ExeMemory:  newCode.CompileResult.ExeMemory,
-			ExitCode:   newCode.CompileResult.ExitCode,
-			ExitSignal: newCode.CompileResult.ExitSignal,
-		})
+		judgeResult.Detail = append(judgeResult.Detail, compileDetail)
 	}
+	judgeResult.applyFeedback(feedback, problemConf.Case)
 	return judgeResult, nil
 }
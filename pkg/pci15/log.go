@@ -2,19 +2,42 @@ package pci15
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
 	"time"
 )
 
+type LogLevel string
+
+const (
+	LevelDebug LogLevel = "debug"
+	LevelInfo  LogLevel = "info"
+	LevelWarn  LogLevel = "warn"
+	LevelError LogLevel = "error"
+)
+
+// LogFields are structured key/value pairs attached to log entries.
+type LogFields map[string]interface{}
+
 type PCILogItem struct {
 	Time    time.Duration `json:"time"`
 	Name    string        `json:"name"`
+	Level   LogLevel      `json:"level,omitempty"`
 	Content string        `json:"content"`
+	Fields  LogFields     `json:"fields,omitempty"`
 }
 
+// PCILog collects the log of one step of judging. It is safe for concurrent
+// use. Steps running in parallel, like test cases, get a Child each, which is
+// merged back when the step is done.
 type PCILog struct {
 	Log     []*PCILogItem `json:"log"`
 	AbsTime time.Time     `json:"time"`
 	Name    string        `json:"name"`
+	fields  LogFields
+	mut     sync.Mutex
 }
 
 func NewPCILog(name string) *PCILog {
@@ -25,25 +48,108 @@ func NewPCILog(name string) *PCILog {
 	}
 }
 
-func (l *PCILog) Append(val string) {
+// Child returns an empty log named name, whose entries carry the fields of l
+// and fields.
+func (l *PCILog) Child(name string, fields LogFields) *PCILog {
+	child := NewPCILog(name)
+	child.fields = make(LogFields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		child.fields[k] = v
+	}
+	for k, v := range fields {
+		child.fields[k] = v
+	}
+	return child
+}
+
+// Entry appends msg with level and fields, in addition to the fields of l.
+func (l *PCILog) Entry(level LogLevel, msg string, fields LogFields) {
+	var all LogFields
+	if len(l.fields)+len(fields) > 0 {
+		all = make(LogFields, len(l.fields)+len(fields))
+		for k, v := range l.fields {
+			all[k] = v
+		}
+		for k, v := range fields {
+			all[k] = v
+		}
+	}
+	l.mut.Lock()
+	defer l.mut.Unlock()
 	l.Log = append(l.Log, &PCILogItem{
 		Time:    time.Now().Sub(l.AbsTime),
 		Name:    l.Name,
-		Content: val,
+		Level:   level,
+		Content: msg,
+		Fields:  all,
 	})
 }
 
+func (l *PCILog) Append(val string) {
+	l.Entry(LevelInfo, val, nil)
+}
+
+func (l *PCILog) Debugf(format string, args ...interface{}) {
+	l.Entry(LevelDebug, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *PCILog) Infof(format string, args ...interface{}) {
+	l.Entry(LevelInfo, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *PCILog) Warnf(format string, args ...interface{}) {
+	l.Entry(LevelWarn, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *PCILog) Errorf(format string, args ...interface{}) {
+	l.Entry(LevelError, fmt.Sprintf(format, args...), nil)
+}
+
 func (l *PCILog) ToJSON() ([]byte, error) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
 	return json.Marshal(l.Log)
 }
 
+// WriteJSONLines writes one entry per line, with absolute times.
+func (l *PCILog) WriteJSONLines(w io.Writer) error {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	enc := json.NewEncoder(w)
+	for _, item := range l.Log {
+		if err := enc.Encode(struct {
+			Time    time.Time `json:"time"`
+			Name    string    `json:"name"`
+			Level   LogLevel  `json:"level,omitempty"`
+			Content string    `json:"content"`
+			Fields  LogFields `json:"fields,omitempty"`
+		}{l.AbsTime.Add(item.Time), item.Name, item.Level, item.Content, item.Fields}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Merge appends the entries of ll, keeping the entries ordered by time.
 func (l *PCILog) Merge(ll *PCILog) {
+	ll.mut.Lock()
+	items := make([]*PCILogItem, 0, len(ll.Log))
 	timeSft := ll.AbsTime.Sub(l.AbsTime)
 	for _, log := range ll.Log {
-		l.Log = append(l.Log, &PCILogItem{
+		items = append(items, &PCILogItem{
 			Time:    log.Time + timeSft,
 			Name:    ll.Name,
+			Level:   log.Level,
 			Content: log.Content,
+			Fields:  log.Fields,
 		})
 	}
+	ll.mut.Unlock()
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.Log = append(l.Log, items...)
+	sort.SliceStable(l.Log, func(a, b int) bool {
+		return l.Log[a].Time < l.Log[b].Time
+	})
 }
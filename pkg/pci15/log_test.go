@@ -0,0 +1,113 @@
+package pci15
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPCILogMerge(t *testing.T) {
+	start := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	item := func(ms int, name, content string) *PCILogItem {
+		return &PCILogItem{Time: time.Duration(ms) * time.Millisecond, Name: name, Level: LevelInfo, Content: content}
+	}
+	log := func(name string, offset int, items ...*PCILogItem) *PCILog {
+		return &PCILog{Name: name, AbsTime: start.Add(time.Duration(offset) * time.Millisecond), Log: items}
+	}
+	tests := []struct {
+		name   string
+		parent *PCILog
+		child  *PCILog
+		want   []*PCILogItem
+	}{
+		{
+			name:   "empty parent",
+			parent: log("judge", 0),
+			child:  log("test 1", 10, item(5, "test 1", "run")),
+			want:   []*PCILogItem{item(15, "test 1", "run")},
+		},
+		{
+			name:   "interleaved",
+			parent: log("judge", 0, item(0, "judge", "start"), item(20, "judge", "compiled"), item(50, "judge", "done")),
+			child:  log("test 1", 10, item(0, "test 1", "run"), item(30, "test 1", "check")),
+			want: []*PCILogItem{
+				item(0, "judge", "start"),
+				item(10, "test 1", "run"),
+				item(20, "judge", "compiled"),
+				item(40, "test 1", "check"),
+				item(50, "judge", "done"),
+			},
+		},
+		{
+			name:   "same time keeps the parent first",
+			parent: log("judge", 0, item(10, "judge", "start")),
+			child:  log("test 1", 10, item(0, "test 1", "run")),
+			want:   []*PCILogItem{item(10, "judge", "start"), item(10, "test 1", "run")},
+		},
+		{
+			name:   "child named after its log",
+			parent: log("judge", 0),
+			child:  log("test 2", 0, item(0, "other", "run")),
+			want:   []*PCILogItem{item(0, "test 2", "run")},
+		},
+	}
+	for _, tt := range tests {
+		tt.parent.Merge(tt.child)
+		if !reflect.DeepEqual(tt.parent.Log, tt.want) {
+			t.Errorf("%s:", tt.name)
+			for _, it := range tt.parent.Log {
+				t.Errorf("  got %v %s %s", it.Time, it.Name, it.Content)
+			}
+			for _, it := range tt.want {
+				t.Errorf("  want %v %s %s", it.Time, it.Name, it.Content)
+			}
+		}
+	}
+}
+
+func TestPCILogChild(t *testing.T) {
+	parent := NewPCILog("judge").Child("judge", LogFields{"submission": "s1", "test": 0})
+	child := parent.Child("test 1", LogFields{"test": 1})
+	child.Entry(LevelWarn, "slow", LogFields{"seconds": 2})
+	child.Append("plain")
+	want := []LogFields{
+		{"submission": "s1", "test": 1, "seconds": 2},
+		{"submission": "s1", "test": 1},
+	}
+	if len(child.Log) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(child.Log), len(want))
+	}
+	for i, it := range child.Log {
+		if !reflect.DeepEqual(it.Fields, want[i]) {
+			t.Errorf("entry %d: got fields %v, want %v", i, it.Fields, want[i])
+		}
+	}
+	if child.Log[0].Level != LevelWarn || child.Log[1].Level != LevelInfo {
+		t.Errorf("got levels %s, %s", child.Log[0].Level, child.Log[1].Level)
+	}
+	if len(parent.Log) != 0 {
+		t.Errorf("entries of a child were added to its parent")
+	}
+}
+
+func TestPCILogWriteJSONLines(t *testing.T) {
+	l := &PCILog{
+		Name:    "judge",
+		AbsTime: time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC),
+		Log: []*PCILogItem{
+			{Time: 1500 * time.Millisecond, Name: "judge", Level: LevelInfo, Content: "compiled"},
+			{Time: 2 * time.Second, Name: "test 1", Level: LevelError, Content: "failed", Fields: LogFields{"test": 1}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := l.WriteJSONLines(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"time":"2019-05-01T12:00:01.5Z","name":"judge","level":"info","content":"compiled"}
+{"time":"2019-05-01T12:00:02Z","name":"test 1","level":"error","content":"failed","fields":{"test":1}}
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,52 @@
+package pci15
+
+import (
+	"context"
+	"os/exec"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/metrics"
+	"github.com/erjiaqing/PCIJudger2/pkg/trace"
+	"github.com/sirupsen/logrus"
+)
+
+// setupMirrorFS creates the chroot sandboxes run in, it returns its name and
+// a function tearing it down. The teardown does not use ctx, it has to run
+// after cancellation too, and runs as well when the setup fails.
+func setupMirrorFS(ctx context.Context, conf *Config, log *PCILog) (string, func(), error) {
+	chrootName := GetRandomString()
+	logrus.Infof("Setting up mirrorfs: /fj_tmp/mirrorfs/%s ...", chrootName)
+	teardown := func() {
+		logrus.Infof("Tearing down mirrorfs: /fj_tmp/mirrorfs/%s ...", chrootName)
+		chrootCmd := exec.Command("/usr/local/bin/lrun-mirrorfs", "--name", chrootName, "--teardown", conf.MirrorFSConfig)
+		_, span := trace.Start(ctx, "mirrorfs_teardown")
+		defer span.Finish()
+		start := time.Now()
+		err := chrootCmd.Run()
+		span.SetError(err)
+		metrics.MirrorFSSeconds.Observe(time.Since(start).Seconds(), "teardown")
+		if err != nil {
+			metrics.MirrorFSFailures.Inc("teardown")
+			log.Entry(LevelError, "Failed to tear down mirrorfs", LogFields{"name": chrootName, "error": err.Error()})
+		} else {
+			log.Entry(LevelInfo, "Tore down mirrorfs", LogFields{"name": chrootName, "seconds": time.Since(start).Seconds()})
+		}
+	}
+
+	chrootCmd := exec.Command("/usr/local/bin/lrun-mirrorfs", "--name", chrootName, "--setup", conf.MirrorFSConfig)
+	_, span := trace.Start(ctx, "mirrorfs_setup")
+	start := time.Now()
+	err := runContext(ctx, chrootCmd)
+	span.SetError(err)
+	span.Finish()
+	metrics.MirrorFSSeconds.Observe(time.Since(start).Seconds(), "setup")
+	if err != nil {
+		metrics.MirrorFSFailures.Inc("setup")
+		log.Entry(LevelError, "Failed to set up mirrorfs", LogFields{"name": chrootName, "error": err.Error()})
+		// a cancelled setup may have mounted part of the chroot
+		teardown()
+		return "", nil, err
+	}
+	log.Entry(LevelInfo, "Set up mirrorfs", LogFields{"name": chrootName, "seconds": time.Since(start).Seconds()})
+	return chrootName, teardown, nil
+}
@@ -0,0 +1,52 @@
+package pci15
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	shutil "github.com/termie/go-shutil"
+)
+
+// ProblemTypeOutputOnly problems are submitted an archive of the outputs of
+// their tests instead of a program, nothing is compiled nor run.
+const ProblemTypeOutputOnly = "output-only"
+
+// submissionDir is where the outputs submitted to output-only problems are
+// unpacked, inside the working directory.
+const submissionDir = "submission"
+
+// outputName is the name of the output of t in submissions to output-only
+// problems, Submit if set, otherwise the input with the .out extension.
+func (t *TestCase) outputName() string {
+	if t.Submit != "" {
+		return t.Submit
+	}
+	name := path.Base(filepath.ToSlash(t.Input))
+	return strings.TrimSuffix(name, path.Ext(name)) + ".out"
+}
+
+// submittedOutput finds the output of t among the files unpacked from the
+// submission, by its path or else by its base name, and copies it to dst. It
+// tells whether the output was submitted.
+func (j *JudgeResult) submittedOutput(workdir string, t *TestCase, dst string) (bool, error) {
+	name := t.outputName()
+	found := ""
+	for _, file := range j.Files {
+		if file == name {
+			found = file
+			break
+		}
+		if found == "" && path.Base(file) == path.Base(name) {
+			found = file
+		}
+	}
+	if found == "" {
+		return false, nil
+	}
+	src := filepath.Join(workdir, submissionDir, filepath.FromSlash(found))
+	if err := shutil.CopyFile(src, dst, false); err != nil {
+		return false, err
+	}
+	return true, nil
+}
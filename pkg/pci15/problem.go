@@ -1,11 +1,13 @@
 package pci15
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/erjiaqing/PCIJudger2/pkg/trace"
 	"github.com/sirupsen/logrus"
 	shutil "github.com/termie/go-shutil"
 	git "gopkg.in/src-d/go-git.v4"
@@ -18,7 +20,16 @@ type BuildResult struct {
 	Log     *PCILog `json:"log"`
 }
 
-func BuildProblem(problem, dest string, conf *Config) (*BuildResult, error) {
+func BuildProblem(ctx context.Context, problem, dest string, conf *Config) (*BuildResult, error) {
+	ctx, span := trace.Start(ctx, "build_problem")
+	span.SetAttr("problem", problem)
+	result, err := buildProblem(ctx, problem, dest, conf)
+	span.SetError(err)
+	span.Finish()
+	return result, err
+}
+
+func buildProblem(ctx context.Context, problem, dest string, conf *Config) (*BuildResult, error) {
 	if dest == "" {
 		dest = problem
 	}
@@ -33,20 +44,8 @@ func BuildProblem(problem, dest string, conf *Config) (*BuildResult, error) {
 			return nil, err
 		}
 	}
-	if currentDir, err := os.Getwd(); err != nil {
-		result.Log.Append(fmt.Sprintf("Failed to get working directory %v", err))
-		return result, err
-	} else if err := os.Chdir(dest); err != nil {
-		result.Log.Append(fmt.Sprintf("Failed to change working directory %v", err))
-		return result, err
-	} else {
-		defer os.Chdir(currentDir)
-	}
-	currdir, _ := os.Getwd()
-	logrus.Infof("Current directory: %s", currdir)
-
 	problemMeta := &ProblemConfig{}
-	if err := loadYAML("problem.yaml", problemMeta); err != nil {
+	if err := loadYAML(filepath.Join(dest, "problem.yaml"), problemMeta); err != nil {
 		result.Log.Append(fmt.Sprintf("Failed to load problem.yaml: %v", err))
 		return result, err
 	}
@@ -66,10 +65,10 @@ func BuildProblem(problem, dest string, conf *Config) (*BuildResult, error) {
 		}
 	}
 
-	if problemMeta.Checker != nil {
+	if problemMeta.Checker != nil && !strings.HasPrefix(problemMeta.Checker.Source, "!") {
 		result.Log.Append(fmt.Sprintf("Compiling checker..."))
 		logrus.Infof("Compiling checker...")
-		compilerOutput, err := problemMeta.Checker.Compile(conf, dest)
+		compilerOutput, err := problemMeta.Checker.Compile(ctx, conf, dest)
 		result.Log.Append("Compiler Output:")
 		result.Log.Append(compilerOutput)
 		logrus.Infof("Compiler Output:")
@@ -82,7 +81,7 @@ func BuildProblem(problem, dest string, conf *Config) (*BuildResult, error) {
 
 	if problemMeta.Interactor != nil {
 		result.Log.Append(fmt.Sprintf("Compiling interactor"))
-		compilerResult, err := problemMeta.Interactor.Compile(conf, dest)
+		compilerResult, err := problemMeta.Interactor.Compile(ctx, conf, dest)
 		result.Log.Append("Compiler Output:")
 		result.Log.Append(compilerResult)
 		if err != nil {
@@ -91,57 +90,99 @@ func BuildProblem(problem, dest string, conf *Config) (*BuildResult, error) {
 		}
 	}
 
+	if err := problemMeta.checkCommunication(); err != nil {
+		result.Success = false
+		result.Log.Append(err.Error())
+		return result, err
+	}
+	if problemMeta.Communication != nil && problemMeta.Communication.Manager != nil {
+		result.Log.Append(fmt.Sprintf("Compiling manager"))
+		compilerResult, err := problemMeta.Communication.Manager.Compile(ctx, conf, dest)
+		result.Log.Append("Compiler Output:")
+		result.Log.Append(compilerResult)
+		if err != nil {
+			result.Success = false
+			return result, err
+		}
+	}
+
+	if problemMeta.Validator != nil {
+		result.Log.Append(fmt.Sprintf("Compiling validator"))
+		compilerResult, err := problemMeta.Validator.Compile(ctx, conf, dest)
+		result.Log.Append("Compiler Output:")
+		result.Log.Append(compilerResult)
+		if err != nil {
+			result.Success = false
+			return result, err
+		}
+	}
+
+	if problemMeta.AnswerGenerator != nil {
+		result.Log.Append(fmt.Sprintf("Compiling answer generator"))
+		compilerResult, err := problemMeta.AnswerGenerator.Compile(ctx, conf, dest)
+		result.Log.Append("Compiler Output:")
+		result.Log.Append(compilerResult)
+		if err != nil {
+			result.Success = false
+			return result, err
+		}
+	}
+
+	result.Log.Append("Running self tests")
+	if err := selfTest(ctx, conf, dest, result.Log); err != nil {
+		result.Success = false
+		return result, err
+	}
+
 	result.Success = true
 	return result, nil
 }
 
-func GetProblem(conf *Config, problem, problemGit, problemVersion string) error {
+func GetProblem(ctx context.Context, conf *Config, problem, problemGit, problemVersion string) error {
 	logrus.Infof("Get problem: %s:%s", problemGit, problemVersion)
+	ctx, span := trace.Start(ctx, "get_problem")
+	span.SetAttr("problem", problem)
+	span.SetAttr("version", problemVersion)
+	defer span.Finish()
 
 	problemDir := filepath.Join(conf.ProblemPath, problem)
 	tmpDir := filepath.Join(conf.Tmp, GetRandomString())
 
-	if currentDir, err := os.Getwd(); err != nil {
-		return err
-	} else if err := os.Chdir(conf.Tmp); err != nil {
-		return err
-	} else {
-		defer os.Chdir(currentDir)
-	}
-
-	repo, err := git.PlainClone(tmpDir, false, &git.CloneOptions{
+	_, cloneSpan := trace.Start(ctx, "git_clone")
+	repo, err := git.PlainCloneContext(ctx, tmpDir, false, &git.CloneOptions{
 		URL: problemGit,
 	})
+	cloneSpan.SetError(err)
+	cloneSpan.Finish()
 
 	if err != nil {
+		span.SetError(err)
 		return err
 	}
 
 	defer os.RemoveAll(tmpDir)
 
-	if currentDir, err := os.Getwd(); err != nil {
-		return err
-	} else if err := os.Chdir(tmpDir); err != nil {
-		return err
-	} else {
-		defer os.Chdir(currentDir)
-	}
-
 	repoTree, err := repo.Worktree()
 	if err != nil {
 		return err
 	}
 
-	if err := repoTree.Checkout(&git.CheckoutOptions{
+	_, checkoutSpan := trace.Start(ctx, "git_checkout")
+	err = repoTree.Checkout(&git.CheckoutOptions{
 		Hash:   plumbing.NewHash(problemVersion),
 		Create: false,
 		Force:  false,
-	}); err != nil {
+	})
+	checkoutSpan.SetError(err)
+	checkoutSpan.Finish()
+	if err != nil {
+		span.SetError(err)
 		return err
 	}
 
-	_, err = BuildProblem(tmpDir, problemDir, conf)
+	_, err = BuildProblem(ctx, tmpDir, problemDir, conf)
 	if err != nil {
+		span.SetError(err)
 		return err
 	}
 	return nil
@@ -0,0 +1,63 @@
+package pci15
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// killGrace is how long a cancelled process group gets between SIGTERM and
+// SIGKILL, lrun uses it to tear down its cgroup and namespaces.
+const killGrace = 2 * time.Second
+
+// startContext starts exe in its own process group and returns a function
+// which waits for it. If ctx is cancelled before the process exits, the whole
+// group is terminated, and the wait function reports ctx.Err().
+func startContext(ctx context.Context, exe *exec.Cmd) (func() error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if exe.SysProcAttr == nil {
+		exe.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	exe.SysProcAttr.Setpgid = true
+	if err := exe.Start(); err != nil {
+		return nil, err
+	}
+	pid := exe.Process.Pid
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		logrus.Warnf("Context cancelled, killing process group %d", pid)
+		syscall.Kill(-pid, syscall.SIGTERM)
+		select {
+		case <-done:
+		case <-time.After(killGrace):
+			syscall.Kill(-pid, syscall.SIGKILL)
+		}
+	}()
+	return func() error {
+		err := exe.Wait()
+		close(done)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}, nil
+}
+
+// runContext runs exe until it exits or ctx is cancelled.
+func runContext(ctx context.Context, exe *exec.Cmd) error {
+	wait, err := startContext(ctx, exe)
+	if err != nil {
+		return err
+	}
+	return wait()
+}
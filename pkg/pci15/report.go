@@ -0,0 +1,223 @@
+package pci15
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// logText returns the content of the entries of l, one per line.
+func logText(l *PCILog) string {
+	if l == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, item := range l.Log {
+		b.WriteString(item.Content)
+		if !strings.HasSuffix(item.Content, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+type junitSuites struct {
+	XMLName  xml.Name      `xml:"testsuites"`
+	Name     string        `xml:"name,attr"`
+	Tests    int           `xml:"tests,attr"`
+	Failures int           `xml:"failures,attr"`
+	Suites   []*junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string       `xml:"name,attr"`
+	Tests     int          `xml:"tests,attr"`
+	Failures  int          `xml:"failures,attr"`
+	Time      float32      `xml:"time,attr"`
+	Cases     []*junitCase `xml:"testcase"`
+	SystemOut *junitText   `xml:"system-out,omitempty"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float32       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+type junitText struct {
+	Text string `xml:",cdata"`
+}
+
+func (s *junitSuite) add(c *junitCase) {
+	s.Cases = append(s.Cases, c)
+	s.Tests++
+	s.Time += c.Time
+	if c.Failure != nil {
+		s.Failures++
+	}
+}
+
+// WriteJUnit writes res as JUnit XML, with a suite for building the problem
+// and one for every test solution, whose cases are the tests. A test fails
+// when it breaks an expectation of the solution, the expectations about no
+// single test are an extra case.
+func (res *CheckResult) WriteJUnit(w io.Writer, name string) error {
+	suites := &junitSuites{Name: name}
+
+	build := &junitSuite{Name: "build"}
+	buildCase := &junitCase{Name: "build", ClassName: "build"}
+	if res.Build != nil {
+		build.SystemOut = &junitText{logText(res.Build.Log)}
+		if !res.Build.Success {
+			buildCase.Failure = &junitFailure{Message: "failed to build problem", Type: "build", Text: build.SystemOut.Text}
+		}
+	} else {
+		buildCase.Failure = &junitFailure{Message: "problem was not built", Type: "build"}
+	}
+	build.add(buildCase)
+	suites.Suites = append(suites.Suites, build)
+
+	for i, run := range res.RunSolutions {
+		suite := &junitSuite{Name: fmt.Sprintf("#%d %s", i+1, run.Source)}
+		byTest := make(map[int][]string)
+		var general []string
+		for _, v := range run.Violations {
+			if v.Test > 0 {
+				byTest[v.Test] = append(byTest[v.Test], v.Message)
+			} else {
+				general = append(general, v.Message)
+			}
+		}
+		for j, detail := range run.Detail {
+			c := &junitCase{Name: detail.Name, ClassName: suite.Name, Time: detail.ExeTime}
+			if c.Name == "" {
+				c.Name = fmt.Sprintf("test %d", j+1)
+			}
+			if messages, ok := byTest[j+1]; ok {
+				c.Failure = &junitFailure{Message: messages[0], Type: detail.Verdict, Text: strings.Join(messages, "\n")}
+			}
+			suite.add(c)
+		}
+		expectations := &junitCase{Name: "expectations", ClassName: suite.Name}
+		if len(general) > 0 {
+			expectations.Failure = &junitFailure{Message: general[0], Type: run.Verdict, Text: strings.Join(general, "\n")}
+		}
+		suite.add(expectations)
+		suite.SystemOut = &junitText{fmt.Sprintf("verdict %s, score %d/%d, expected %s", run.Verdict, run.Score, run.FullScore, strings.Join(run.Expected, ", "))}
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if res.TimeLimit != nil {
+		suite := &junitSuite{Name: "timelimit"}
+		c := &junitCase{Name: "safe time limit", ClassName: "timelimit"}
+		if !res.TimeLimit.Safe {
+			c.Failure = &junitFailure{
+				Message: fmt.Sprintf("suggested %dms is not below the fastest TLE solution, %.3fs", res.TimeLimit.Suggested, res.TimeLimit.FastestTLE),
+				Type:    "timelimit",
+			}
+		}
+		suite.add(c)
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	for _, suite := range suites.Suites {
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+pre { background: #f6f6f6; padding: 1em; overflow: auto; }
+.ok { color: #1a7f37; }
+.fail { color: #cf222e; }
+.AC { background: #dafbe1; }
+.WA, .RE, .MLE, .OLE, .SE { background: #ffebe9; }
+.TLE, .ILE { background: #fff8c5; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}: {{if .Result.Success}}<span class="ok">passed</span>{{else}}<span class="fail">failed</span>{{end}}</h1>
+
+<h2>Test solutions</h2>
+<table>
+<tr><th>#</th><th>solution</th><th>verdict</th><th>score</th><th>time</th><th>expected</th><th>result</th></tr>
+{{range $i, $run := .Result.RunSolutions}}<tr>
+<td>{{inc $i}}</td><td>{{$run.Source}}</td><td class="{{$run.Verdict}}">{{$run.Verdict}}</td><td>{{$run.Score}}/{{$run.FullScore}}</td><td>{{$run.ExeTime}}</td>
+<td>{{range $run.Expected}}{{.}} {{end}}</td>
+<td>{{if $run.Violations}}<span class="fail">{{range $run.Violations}}{{.Message}}<br>{{end}}</span>{{else}}<span class="ok">ok</span>{{end}}</td>
+</tr>
+{{end}}</table>
+
+{{with .Result.Coverage}}<h2>Verdicts</h2>
+<table>
+<tr><th>test</th>{{range $i, $sol := .Solutions}}<th title="{{$sol.Source}}">#{{inc $i}}{{if not $sol.Wrong}} (AC){{end}}</th>{{end}}<th>kills</th></tr>
+{{range .Tests}}<tr>
+<td>{{.Name}}</td>{{range .Verdicts}}<td class="{{.}}">{{.}}</td>{{end}}<td>{{len .Kills}}{{if .Redundant}} <span class="fail">redundant</span>{{end}}</td>
+</tr>
+{{end}}</table>
+<table>
+<tr><th>#</th><th>solution</th><th>first kill</th><th>killed by</th></tr>
+{{range $i, $sol := .Solutions}}{{if $sol.Wrong}}<tr>
+<td>{{inc $i}}</td><td>{{$sol.Source}}</td><td>{{if $sol.Survived}}<span class="fail">survived</span>{{else}}{{$sol.FirstKill}}{{end}}</td>
+<td>{{len $sol.KilledBy}}{{if $sol.Fragile}} <span class="fail">fragile</span>{{end}}</td>
+</tr>{{end}}
+{{end}}</table>
+{{end}}
+
+{{with .Result.TimeLimit}}<h2>Time limit</h2>
+<p>Current {{.Current}}ms, suggested {{.Suggested}}ms: slowest AC {{.SlowestAC}}s{{if .FastestTLE}}, fastest TLE {{.FastestTLE}}s, margin {{.Margin}}s{{end}}.
+{{if .Safe}}<span class="ok">safe</span>{{else}}<span class="fail">not safe</span>{{end}}</p>
+{{end}}
+
+<h2>Build</h2>
+{{if .Result.Build}}<p>{{if .Result.Build.Success}}<span class="ok">built</span>{{else}}<span class="fail">failed</span>{{end}}</p>{{end}}
+<pre>{{.BuildLog}}</pre>
+</body>
+</html>
+`))
+
+// WriteHTML writes res as a standalone HTML page, with the verdict of every
+// test solution on every test and the build log.
+func (res *CheckResult) WriteHTML(w io.Writer, name string) error {
+	data := struct {
+		Name     string
+		Result   *CheckResult
+		BuildLog string
+	}{
+		Name:   name,
+		Result: res,
+	}
+	if res.Build != nil {
+		data.BuildLog = logText(res.Build.Log)
+	}
+	return reportTemplate.Execute(w, data)
+}
@@ -0,0 +1,149 @@
+package pci15
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLogText(t *testing.T) {
+	tests := []struct {
+		name string
+		log  *PCILog
+		want string
+	}{
+		{"nil", nil, ""},
+		{"empty", &PCILog{}, ""},
+		{"lines", &PCILog{Log: []*PCILogItem{{Content: "g++ main.cpp"}, {Content: "error: x\n"}, {Content: "done"}}}, "g++ main.cpp\nerror: x\ndone\n"},
+	}
+	for _, tt := range tests {
+		if got := logText(tt.log); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	built := &BuildResult{Success: true, Log: &PCILog{Log: []*PCILogItem{{Content: "built"}}}}
+	run := func(source, verdict string, violations ...*Violation) *CheckRun {
+		return &CheckRun{
+			JudgeResult: &JudgeResult{Verdict: verdict, Detail: []*JudgeDetail{{Name: "1", Verdict: "AC"}, {Verdict: verdict}}},
+			Source:      source,
+			Expected:    []string{"AC"},
+			Violations:  violations,
+		}
+	}
+	// suite is a suite of the report, with the message of every failed case
+	type suite struct {
+		name     string
+		cases    []string
+		failures map[string]string
+	}
+	tests := []struct {
+		name string
+		res  *CheckResult
+		want []suite
+	}{
+		{
+			name: "passed",
+			res:  &CheckResult{Success: true, Build: built, RunSolutions: []*CheckRun{run("ac.cpp", "AC")}},
+			want: []suite{
+				{"build", []string{"build"}, nil},
+				{"#1 ac.cpp", []string{"1", "test 2", "expectations"}, nil},
+			},
+		},
+		{
+			name: "not built",
+			res:  &CheckResult{},
+			want: []suite{{"build", []string{"build"}, map[string]string{"build": "problem was not built"}}},
+		},
+		{
+			name: "build failed",
+			res:  &CheckResult{Build: &BuildResult{Log: &PCILog{}}},
+			want: []suite{{"build", []string{"build"}, map[string]string{"build": "failed to build problem"}}},
+		},
+		{
+			name: "violations",
+			res: &CheckResult{Build: built, RunSolutions: []*CheckRun{run("wa.cpp", "WA",
+				&Violation{2, "test 2 got WA"}, &Violation{2, "test 2 again"}, &Violation{0, "verdict is WA"})}},
+			want: []suite{
+				{"build", []string{"build"}, nil},
+				{"#1 wa.cpp", []string{"1", "test 2", "expectations"}, map[string]string{"test 2": "test 2 got WA", "expectations": "verdict is WA"}},
+			},
+		},
+		{
+			name: "time limit",
+			res:  &CheckResult{Build: built, TimeLimit: &TimeLimitSuggestion{Suggested: 1000, FastestTLE: 0.9}},
+			want: []suite{
+				{"build", []string{"build"}, nil},
+				{"timelimit", []string{"safe time limit"}, map[string]string{"safe time limit": "suggested 1000ms is not below the fastest TLE solution, 0.900s"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := tt.res.WriteJUnit(&buf, "problem"); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got := &junitSuites{}
+		if err := xml.Unmarshal(buf.Bytes(), got); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var gotSuites []suite
+		tests, failures := 0, 0
+		for _, s := range got.Suites {
+			gs := suite{name: s.Name}
+			for _, c := range s.Cases {
+				gs.cases = append(gs.cases, c.Name)
+				if c.Failure != nil {
+					if gs.failures == nil {
+						gs.failures = make(map[string]string)
+					}
+					gs.failures[c.Name] = c.Failure.Message
+				}
+			}
+			if s.Tests != len(s.Cases) || s.Failures != len(gs.failures) {
+				t.Errorf("%s: suite %s counts %d tests and %d failures", tt.name, s.Name, s.Tests, s.Failures)
+			}
+			tests += s.Tests
+			failures += s.Failures
+			gotSuites = append(gotSuites, gs)
+		}
+		if !reflect.DeepEqual(gotSuites, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, gotSuites, tt.want)
+		}
+		if got.Name != "problem" || got.Tests != tests || got.Failures != failures {
+			t.Errorf("%s: got %s with %d tests and %d failures, want %d and %d", tt.name, got.Name, got.Tests, got.Failures, tests, failures)
+		}
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	res := &CheckResult{
+		Build: &BuildResult{Success: true, Log: &PCILog{Log: []*PCILogItem{{Content: "gcc <checker>"}}}},
+		RunSolutions: []*CheckRun{{
+			JudgeResult: &JudgeResult{Verdict: "WA"},
+			Source:      "wa<1>.cpp",
+			Violations:  []*Violation{{0, "verdict is WA, expected AC"}},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := res.WriteHTML(&buf, "sum"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<title>sum</title>",
+		`<span class="fail">failed</span>`,
+		"<td>wa&lt;1&gt;.cpp</td>",
+		"verdict is WA, expected AC<br>",
+		"<pre>gcc &lt;checker&gt;\n</pre>",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report has no %q", want)
+		}
+	}
+}
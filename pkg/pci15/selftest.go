@@ -0,0 +1,232 @@
+package pci15
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/builtin_cmp"
+	"github.com/sirupsen/logrus"
+)
+
+// CheckerTest is a check the checker of the problem must decide as expected.
+// Paths are relative to the problem.
+type CheckerTest struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	Answer string `json:"answer"`
+	// Verdict is AC or WA, AC if neither it nor Score is set
+	Verdict string `json:"verdict,omitempty"`
+	// Score is in percent of the score of a test, checkers give all or
+	// nothing so it is 100 for AC and 0 for WA
+	Score *int `json:"score,omitempty"`
+}
+
+func (t *CheckerTest) expected() string {
+	if t.Verdict != "" {
+		return t.Verdict
+	}
+	if t.Score != nil && *t.Score < 100 {
+		return "WA"
+	}
+	return "AC"
+}
+
+// InteractorStep is a line sent to the interactor as the contestant, or a
+// line the interactor must print next, compared without the surrounding
+// whitespace.
+type InteractorStep struct {
+	Send   *string `json:"send,omitempty"`
+	Expect *string `json:"expect,omitempty"`
+}
+
+// InteractorTest is a scripted exchange with the interactor, which ends with
+// the verdict of the interactor. Paths are relative to the problem.
+type InteractorTest struct {
+	Input   string            `json:"input"`
+	Answer  string            `json:"answer"`
+	Script  []*InteractorStep `json:"script"`
+	Verdict string            `json:"verdict,omitempty"` // AC if empty
+}
+
+const selfTestTimeout = 10 * time.Second
+
+func withComment(msg, comment string) string {
+	if comment = strings.TrimSpace(comment); comment != "" {
+		return msg + ": " + comment
+	}
+	return msg
+}
+
+// selfTest runs the checker and interactor tests of the problem in dir, and
+// checks every answer of the problem against itself, checkpoints aside. The
+// problem must have been built.
+func selfTest(ctx context.Context, conf *Config, dir string, log *PCILog) error {
+	problemConf := &ProblemConfig{}
+	if err := loadYAML(filepath.Join(dir, "problem.yaml"), problemConf); err != nil {
+		return err
+	}
+	if problemConf.MemoryLimit == 0 {
+		problemConf.MemoryLimit = defaultCustomMemoryLimit
+	}
+	checker := "!diff"
+	if problemConf.Checker != nil && problemConf.Checker.Source != "" {
+		checker = problemConf.Checker.Source
+	}
+	if _, ok := builtin_cmp.Diff[checker]; checker[0] == '!' && !ok {
+		return fmt.Errorf("unknown builtin checker %s", checker)
+	}
+
+	tmpDir, err := filepath.Abs(conf.Tmp)
+	if err != nil {
+		return err
+	}
+	workDir := filepath.Join(tmpDir, GetRandomString())
+	if err := os.MkdirAll(workDir, 0777); err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	var failures []string
+	var checkerCmd []string
+	if checker[0] != '!' {
+		checkerCmd = problemCommand(conf, dir, "checker", problemConf.Checker, log)
+	}
+	check := func(name, input, output, answer, expected string) error {
+		verdict, comment, err := runChecker(ctx, checker, checkerCmd, problemConf.MemoryLimit, workDir, filepath.Join(dir, input), filepath.Join(dir, output), filepath.Join(dir, answer))
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if verdict != expected {
+			failures = append(failures, withComment(fmt.Sprintf("%s: checker gave %s, expected %s", name, verdict, expected), comment))
+		}
+		return nil
+	}
+
+	if problemConf.Interactor == nil {
+		for i, testCase := range problemConf.Case {
+			if strings.HasPrefix(testCase.Input, "*") {
+				// checkpoints have no answer
+				continue
+			}
+			name := fmt.Sprintf("answer of test %d (%s)", i+1, testCase.Input)
+			if err := check(name, testCase.Input, testCase.Output, testCase.Output, "AC"); err != nil {
+				return err
+			}
+		}
+	}
+	for i, t := range problemConf.CheckerTests {
+		name := fmt.Sprintf("checker test %d (%s)", i+1, t.Output)
+		if err := check(name, t.Input, t.Output, t.Answer, t.expected()); err != nil {
+			return err
+		}
+	}
+
+	if len(problemConf.InteractorTests) > 0 {
+		if problemConf.Interactor == nil {
+			return fmt.Errorf("interactor tests are given but the problem has no interactor")
+		}
+		interCmd := problemCommand(conf, dir, "interactor", problemConf.Interactor, log)
+		for i, t := range problemConf.InteractorTests {
+			name := fmt.Sprintf("interactor test %d (%s)", i+1, t.Input)
+			if failure := runInteractorTest(ctx, interCmd, dir, workDir, t); failure != "" {
+				failures = append(failures, fmt.Sprintf("%s: %s", name, failure))
+			}
+		}
+	}
+
+	log.Append(fmt.Sprintf("Self tests: %d failed", len(failures)))
+	for _, failure := range failures {
+		log.Append(failure)
+		logrus.Warnf("Self test failed: %s", failure)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d self tests failed, first: %s", len(failures), failures[0])
+	}
+	return nil
+}
+
+// runInteractorTest plays the script of t as the contestant, and returns why
+// the interactor did not behave as expected, or nothing.
+func runInteractorTest(ctx context.Context, interCmd []string, dir, workDir string, t *InteractorTest) string {
+	ctx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+	defer cancel()
+
+	output := filepath.Join(workDir, "interactor.out")
+	stderr := filepath.Join(workDir, "interactor.stderr")
+	args := append(interCmd[1:len(interCmd):len(interCmd)], filepath.Join(dir, t.Input), output, filepath.Join(dir, t.Answer))
+	exe := exec.Command(interCmd[0], args...)
+	exe.Dir = workDir
+	fp, err := os.Create(stderr)
+	if err != nil {
+		return err.Error()
+	}
+	defer fp.Close()
+	exe.Stderr = fp
+	stdin, err := exe.StdinPipe()
+	if err != nil {
+		return err.Error()
+	}
+	stdout, err := exe.StdoutPipe()
+	if err != nil {
+		return err.Error()
+	}
+	wait, err := startContext(ctx, exe)
+	if err != nil {
+		return fmt.Sprintf("failed to start interactor: %v", err)
+	}
+
+	failure := ""
+	reader := bufio.NewReader(stdout)
+	for i, step := range t.Script {
+		if step.Send != nil {
+			if _, err := io.WriteString(stdin, *step.Send+"\n"); err != nil {
+				failure = fmt.Sprintf("step %d: interactor stopped reading: %v", i+1, err)
+				break
+			}
+		}
+		if step.Expect != nil {
+			line, err := reader.ReadString('\n')
+			if err != nil && line == "" {
+				failure = fmt.Sprintf("step %d: interactor printed nothing, expected %q", i+1, *step.Expect)
+				break
+			}
+			if strings.TrimSpace(line) != strings.TrimSpace(*step.Expect) {
+				failure = fmt.Sprintf("step %d: interactor printed %q, expected %q", i+1, strings.TrimSpace(line), *step.Expect)
+				break
+			}
+		}
+	}
+	stdin.Close()
+	if failure != "" {
+		cancel()
+	}
+	io.Copy(ioutil.Discard, reader)
+	err = wait()
+	if failure != "" {
+		return failure
+	}
+	if ctx.Err() != nil {
+		return "interactor did not finish in time"
+	}
+	verdict := "AC"
+	if err != nil {
+		verdict = "WA"
+	}
+	expected := t.Verdict
+	if expected == "" {
+		expected = "AC"
+	}
+	if verdict != expected {
+		comment, _ := ReadFirstBytes(stderr, 128)
+		return withComment(fmt.Sprintf("interactor gave %s, expected %s", verdict, expected), comment)
+	}
+	return ""
+}
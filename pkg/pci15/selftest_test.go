@@ -0,0 +1,94 @@
+package pci15
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		failure string // in the error, empty when the self test passes
+	}{
+		{
+			// the checker is not run on checkpoints, even when they name an
+			// answer
+			name: "checkpoint",
+			yaml: `case:
+- input: 1.in
+  output: 1.ans
+- input: "*after1"
+  output: after1.ans
+  dep: [1.in]
+- input: 2.in
+  output: 2.ans
+`,
+		},
+		{
+			name: "checker tests",
+			yaml: `case:
+- input: 1.in
+  output: 1.ans
+checker_test:
+- input: 1.in
+  output: 2.ans
+  answer: 1.ans
+  verdict: WA
+- input: 1.in
+  output: 1.ans
+  answer: 1.ans
+`,
+		},
+		{
+			name: "checker test failed",
+			yaml: `case:
+- input: 1.in
+  output: 1.ans
+checker_test:
+- input: 1.in
+  output: 2.ans
+  answer: 1.ans
+`,
+			failure: "checker test 1 (2.ans): checker gave WA, expected AC",
+		},
+		{
+			name: "missing answer",
+			yaml: `case:
+- input: 1.in
+  output: 3.ans
+`,
+			failure: "answer of test 1 (1.in): checker gave WA, expected AC",
+		},
+	}
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "selftest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		files := map[string]string{
+			"problem.yaml": tt.yaml,
+			"1.in":         "1\n",
+			"1.ans":        "1\n",
+			"2.in":         "2\n",
+			"2.ans":        "2\n",
+		}
+		for name, content := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		conf := &Config{Tmp: dir}
+		err = selfTest(context.Background(), conf, dir, NewPCILog("selftest"))
+		if tt.failure == "" && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if tt.failure != "" && (err == nil || !strings.Contains(err.Error(), tt.failure)) {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.failure)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package pci15
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -9,14 +10,17 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/erjiaqing/PCIJudger2/pkg/metrics"
+	"github.com/erjiaqing/PCIJudger2/pkg/trace"
 	"github.com/sirupsen/logrus"
 )
 
 type ExecuteCommand struct {
-	Source     string
-	Executable string
-	Compile    []string
-	Execute    []string
+	Source       string
+	Executable   string
+	Compile      []string
+	MultiCompile []string // with the {sources} argument left to expand
+	Execute      []string
 }
 
 func GetExecuteCommand(code *SourceCode, conf *Config) (*ExecuteCommand, *Language, error) {
@@ -27,48 +31,25 @@ func GetExecuteCommand2(code *SourceCode, conf *Config, path string, ignoreFileN
 	if code == nil {
 		return nil, nil, errors.New("code is nil")
 	}
-	Variables := make(map[string]string)
-	SourceCode, err := ReadFile(code.Source)
-	if err != nil {
-		return nil, nil, err
-	}
-
 	if path == "" {
 		cwd, _ := os.Getwd()
 		path = cwd
 	}
 
 	if !ignoreFileName && !filepath.IsAbs(code.Source) {
-		if err == nil {
-			code.Source = filepath.Join(path, code.Source)
-		}
+		code.Source = filepath.Join(path, code.Source)
+	}
+
+	SourceCode, err := ReadFile(code.Source)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	language := &Language{}
 	if err := loadYAML(filepath.Join(conf.LanguageStorage, code.Language+".yaml"), language); err != nil {
 		return nil, nil, err
 	}
-	if language.Variable != nil {
-		for _, variable := range language.Variable {
-			name := variable.Name
-			switch variable.Type {
-			case "regexp":
-				res, err := regexp.Compile(variable.Value)
-				if err != nil {
-					logrus.Warningf("Unable to compile %s: %v", variable.Value, err)
-					continue
-				}
-				matchRes := res.FindStringSubmatch(SourceCode)
-				if variable.Match < len(matchRes) && variable.Match >= 0 {
-					Variables[name] = matchRes[variable.Match]
-					logrus.Infof("[%s] -> %s\n", name, matchRes[variable.Match])
-				}
-			case "string":
-				Variables[name] = variable.Value
-				logrus.Infof("[%s] -> %s\n", name, variable.Value)
-			}
-		}
-	}
+	Variables := language.variables(SourceCode)
 	ret := &ExecuteCommand{}
 
 	if ignoreFileName {
@@ -86,6 +67,9 @@ func GetExecuteCommand2(code *SourceCode, conf *Config, path string, ignoreFileN
 		sourceWOsuffix = len(ret.Source)
 	}
 	Variables["source<"] = ret.Source[:sourceWOsuffix]
+	for k, v := range code.Variables {
+		Variables[k] = v
+	}
 
 	ret.Executable = language.Executable
 	for k, v := range Variables {
@@ -99,6 +83,12 @@ func GetExecuteCommand2(code *SourceCode, conf *Config, path string, ignoreFileN
 		}
 		ret.Compile = append(ret.Compile, str)
 	}
+	for _, str := range language.Compile.MultiCmd {
+		for k, v := range Variables {
+			str = strings.Replace(str, "{"+k+"}", v, 1000000)
+		}
+		ret.MultiCompile = append(ret.MultiCompile, str)
+	}
 	for _, str := range language.Execute.Cmd {
 		for k, v := range Variables {
 			str = strings.Replace(str, "{"+k+"}", v, 1000000)
@@ -108,33 +98,127 @@ func GetExecuteCommand2(code *SourceCode, conf *Config, path string, ignoreFileN
 	return ret, language, nil
 }
 
-func (code *SourceCode) Compile(conf *Config, workdir string) (string, error) {
-	return code.Compile2(conf, workdir, false)
+// variables returns the variables of l, those matched against source.
+func (l *Language) variables(source string) map[string]string {
+	Variables := make(map[string]string)
+	if l.Variable != nil {
+		for _, variable := range l.Variable {
+			name := variable.Name
+			switch variable.Type {
+			case "regexp":
+				res, err := regexp.Compile(variable.Value)
+				if err != nil {
+					logrus.Warningf("Unable to compile %s: %v", variable.Value, err)
+					continue
+				}
+				matchRes := res.FindStringSubmatch(source)
+				if variable.Match < len(matchRes) && variable.Match >= 0 {
+					Variables[name] = matchRes[variable.Match]
+					logrus.Infof("[%s] -> %s\n", name, matchRes[variable.Match])
+				}
+			case "string":
+				Variables[name] = variable.Value
+				logrus.Infof("[%s] -> %s\n", name, variable.Value)
+			}
+		}
+	}
+	return Variables
 }
 
-func (code *SourceCode) Compile2(conf *Config, workdir string, ignoreFileName bool) (string, error) {
+// compileIn copies code into dir, named as its language wants, and compiles
+// it there like Judge does with submissions. The command is nil when code
+// could not be prepared for compiling, otherwise err is a compile error.
+func compileIn(ctx context.Context, conf *Config, code *SourceCode, dir string) (*ExecuteCommand, *Language, *SourceCode, string, error) {
+	execCommand, language, err := GetExecuteCommand2(code, conf, dir, true)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+	codeBin, err := ioutil.ReadFile(code.Source)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+	if err := ioutil.WriteFile(execCommand.Source, codeBin, 0644); err != nil {
+		return nil, nil, nil, "", fmt.Errorf("failed to copy source: %v", err)
+	}
+	newCode := &SourceCode{
+		Source:   execCommand.Source,
+		Language: code.Language,
+	}
+	output, err := newCode.Compile2(ctx, conf, dir, true)
+	return execCommand, language, newCode, output, err
+}
+
+func (code *SourceCode) Compile(ctx context.Context, conf *Config, workdir string) (string, error) {
+	return code.Compile2(ctx, conf, workdir, false)
+}
+
+// Compile2 compiles code inside workdir, the compiler runs with workdir as its
+// working directory and relative paths in the language config resolve to it.
+func (code *SourceCode) Compile2(ctx context.Context, conf *Config, workdir string, ignoreFileName bool) (string, error) {
+	ctx, span := trace.Start(ctx, "compile")
+	span.SetAttr("lang", code.Language)
+	span.SetAttr("source", code.Source)
+	output, err := code.compile(ctx, conf, workdir, ignoreFileName)
+	if code.CompileResult != nil {
+		traceResult(span, "", code.CompileResult)
+	}
+	span.SetError(err)
+	span.Finish()
+	return output, err
+}
+
+func (code *SourceCode) compile(ctx context.Context, conf *Config, workdir string, ignoreFileName bool) (string, error) {
 	logrus.Infof("Language: %s", code.Language)
 
-	if currentDir, err := os.Getwd(); err != nil {
-		return "", err
-	} else if err := os.Chdir(workdir); err != nil {
+	workdir, err := filepath.Abs(workdir)
+	if err != nil {
 		return "", err
-	} else {
-		defer os.Chdir(currentDir)
 	}
 
-	compileCfg, lang, err := GetExecuteCommand2(code, conf, workdir, ignoreFileName)
+	var compileCfg *ExecuteCommand
+	var lang *Language
+	if code.Project {
+		compileCfg, lang, err = GetProjectCommand(code, conf, workdir)
+	} else {
+		compileCfg, lang, err = GetExecuteCommand2(code, conf, workdir, ignoreFileName)
+	}
 	if err != nil {
 		return "", err
 	}
 
-	compileRes, err := Execute(compileCfg.Compile, lang.Compile.TimeLimit, 1024*1024*1024, 1.0, "", "", false, "-", "-", "compile_error")
+	command := compileCfg.Compile
+	if len(code.Extra) > 0 {
+		if len(compileCfg.MultiCompile) == 0 {
+			return "", fmt.Errorf("%s does not support compiling several files", code.Language)
+		}
+		command = expandSources(compileCfg.MultiCompile, append([]string{compileCfg.Source}, code.Extra...))
+	}
+
+	timeLimit := lang.Compile.TimeLimit
+	if code.Project {
+		project := lang.project(workdir)
+		if len(project.Cmd) == 0 {
+			// nothing to build, like a python project
+			return "", nil
+		}
+		if project.TimeLimit != 0 {
+			timeLimit = project.TimeLimit
+		}
+	}
+
+	compileError := filepath.Join(workdir, "compile_error")
+	compileRes, err := Execute(ctx, command, timeLimit, 1024*1024*1024, 1.0, "", workdir, false, "-", "-", compileError)
 	if err != nil {
 		return "", err
 	}
 	code.CompileResult = compileRes
-	_, err = os.Stat(compileCfg.Executable)
-	compilerStderr, _ := ioutil.ReadFile("compile_error")
+	metrics.CompileSeconds.Observe(float64(compileRes.RealTime), code.Language)
+	executable := compileCfg.Executable
+	if !filepath.IsAbs(executable) {
+		executable = filepath.Join(workdir, executable)
+	}
+	_, err = os.Stat(executable)
+	compilerStderr, _ := ioutil.ReadFile(compileError)
 	if code.CompileResult.ExitCode != 0 || code.CompileResult.ExitSignal != 0 || code.CompileResult.TermSignal != 0 || err != nil {
 		return string(compilerStderr), errors.New("CE")
 	}
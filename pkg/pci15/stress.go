@@ -0,0 +1,270 @@
+package pci15
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/erjiaqing/PCIJudger2/pkg/builtin_cmp"
+	"github.com/erjiaqing/PCIJudger2/pkg/trace"
+	"github.com/sirupsen/logrus"
+	shutil "github.com/termie/go-shutil"
+)
+
+type StressOptions struct {
+	Generator *SourceCode `json:"generator"` // run as `gen <seed>`, prints the input
+	Trusted   *SourceCode `json:"trusted"`
+	Suspect   *SourceCode `json:"suspect"`
+	Seed      int64       `json:"seed"`  // first seed
+	Count     int         `json:"count"` // seeds tried
+	// Checker is a builtin checker used instead of the one of the problem
+	Checker string `json:"checker,omitempty"`
+	// SaveDir is where the failing test is saved, the stress directory of
+	// the problem if empty
+	SaveDir string `json:"save,omitempty"`
+}
+
+const defaultStressCount = 100
+
+type StressResult struct {
+	Found      bool   `json:"found"`
+	Seed       int64  `json:"seed,omitempty"`
+	Iterations int    `json:"iterations"`
+	Verdict    string `json:"verdict,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	// Input, Answer and Output are the saved input and outputs of the trusted
+	// and suspect solutions
+	Input  string  `json:"input,omitempty"`
+	Answer string  `json:"answer,omitempty"`
+	Output string  `json:"output,omitempty"`
+	Log    *PCILog `json:"log,omitempty"`
+}
+
+type stressProgram struct {
+	name     string
+	dir      string
+	exec     *ExecuteCommand
+	language *Language
+}
+
+// compileStress compiles code into its own directory under workDir, the
+// three programs may want the same source name.
+func compileStress(ctx context.Context, conf *Config, name string, code *SourceCode, workDir string, log *PCILog) (*stressProgram, error) {
+	if code == nil || code.Source == "" {
+		return nil, fmt.Errorf("no %s given", name)
+	}
+	dir := filepath.Join(workDir, name)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	execCommand, language, _, output, err := compileIn(ctx, conf, code, dir)
+	if err != nil {
+		log.Entry(LevelError, "Compilation failed", LogFields{"program": name, "error": err.Error(), "output": output})
+		return nil, fmt.Errorf("failed to compile %s: %v", name, err)
+	}
+	log.Entry(LevelInfo, "Compiled", LogFields{"program": name, "lang": code.Language})
+	return &stressProgram{
+		name:     name,
+		dir:      dir,
+		exec:     execCommand,
+		language: language,
+	}, nil
+}
+
+// Stress compiles a generator, a trusted and a suspect solution, then runs
+// them on the inputs of consecutive seeds until the suspect solution fails
+// or its output is rejected by the checker. The failing test is saved with
+// its seed.
+func Stress(ctx context.Context, conf *Config, problem string, opts *StressOptions) (*StressResult, error) {
+	ctx, span := trace.Start(ctx, "stress")
+	defer span.Finish()
+
+	log := NewPCILog("stress")
+	result := &StressResult{Log: log}
+
+	problemConf := &ProblemConfig{}
+	if err := loadYAML(filepath.Join(problem, "problem.yaml"), problemConf); err != nil {
+		return nil, fmt.Errorf("failed to load problem.yaml: %v", err)
+	}
+	if problemConf.Interactor != nil {
+		return nil, errors.New("stress testing interactive problems is not supported")
+	}
+	if problemConf.Type == ProblemTypeOutputOnly {
+		return nil, errors.New("stress testing output-only problems is not supported")
+	}
+	if problemConf.Type == ProblemTypeCommunication {
+		return nil, errors.New("stress testing communication problems is not supported")
+	}
+	if problemConf.TimeLimit == 0 {
+		problemConf.TimeLimit = problemConf.TimeLimitBK
+	}
+	if problemConf.TimeLimit == 0 {
+		problemConf.TimeLimit = 1000
+	}
+	if problemConf.MemoryLimit == 0 {
+		problemConf.MemoryLimit = defaultCustomMemoryLimit
+	}
+	if _, ok := builtin_cmp.Diff[opts.Checker]; opts.Checker != "" && !ok {
+		return nil, fmt.Errorf("unknown builtin checker %s", opts.Checker)
+	}
+	checker := opts.Checker
+	if checker == "" && problemConf.Checker != nil {
+		// a checker given only by its executable is run as it is
+		checker = problemConf.Checker.Source
+		if checker == "" {
+			checker = problemConf.Checker.Executable
+		}
+	}
+	if checker == "" {
+		checker = "!diff"
+	}
+	if _, ok := builtin_cmp.Diff[checker]; checker[0] == '!' && !ok {
+		return nil, fmt.Errorf("unknown builtin checker %s", checker)
+	}
+	count := opts.Count
+	if count <= 0 {
+		count = defaultStressCount
+	}
+	saveDir := opts.SaveDir
+	if saveDir == "" {
+		saveDir = filepath.Join(problem, "stress")
+	}
+
+	tmpDir, err := filepath.Abs(conf.Tmp)
+	if err != nil {
+		return nil, err
+	}
+	workDir := filepath.Join(tmpDir, GetRandomString())
+	if err := os.MkdirAll(workDir, 0777); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	generator, err := compileStress(ctx, conf, "generator", opts.Generator, workDir, log)
+	if err != nil {
+		return nil, err
+	}
+	trusted, err := compileStress(ctx, conf, "trusted", opts.Trusted, workDir, log)
+	if err != nil {
+		return nil, err
+	}
+	suspect, err := compileStress(ctx, conf, "suspect", opts.Suspect, workDir, log)
+	if err != nil {
+		return nil, err
+	}
+	var checkerCmd []string
+	if checker[0] != '!' {
+		checkerCmd = problemCommand(conf, problem, "checker", problemConf.Checker, log)
+	}
+
+	chrootName, teardown, err := setupMirrorFS(ctx, conf, log)
+	if err != nil {
+		return nil, err
+	}
+	defer teardown()
+	chroot := filepath.Join("/fj_tmp/mirrorfs", chrootName)
+
+	inputFile := filepath.Join(workDir, "stress.in")
+	answerFile := filepath.Join(workDir, "stress.ans")
+	outputFile := filepath.Join(workDir, "stress.out")
+	stderrFile := filepath.Join(workDir, "stress.stderr")
+	timeLimit := float32(problemConf.TimeLimit) / 1000.
+	memoryLimit := problemConf.MemoryLimit * 1024 * 1024
+
+	// run runs prog and fails unless it exits normally
+	run := func(prog *stressProgram, cmd []string, timeLimit float32, stdin, stdout string) (*ExecuteResult, error) {
+		res, err := Execute(ctx, cmd, timeLimit, memoryLimit, prog.language.Execute.TimeRatio, chroot, prog.dir, true, stdin, stdout, stderrFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run %s: %v", prog.name, err)
+		}
+		return res, nil
+	}
+	failed := func(res *ExecuteResult) string {
+		if res.ExitReason != "none" {
+			return res.ExitReason
+		}
+		if res.ExitCode != 0 || res.ExitSignal != 0 || res.TermSignal != 0 {
+			return "RE"
+		}
+		return ""
+	}
+
+	for i := 0; i < count; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		seed := opts.Seed + int64(i)
+		result.Iterations = i + 1
+
+		genCmd := append(generator.exec.Execute[:len(generator.exec.Execute):len(generator.exec.Execute)], strconv.FormatInt(seed, 10))
+		res, err := run(generator, genCmd, 10., "-", inputFile)
+		if err != nil {
+			return nil, err
+		}
+		if verdict := failed(res); verdict != "" {
+			stderr, _ := ReadFirstBytes(stderrFile, 1024)
+			log.Entry(LevelError, "Generator failed", LogFields{"seed": seed, "verdict": verdict, "stderr": stderr})
+			return nil, fmt.Errorf("generator failed on seed %d: %s", seed, verdict)
+		}
+
+		res, err = run(trusted, trusted.exec.Execute, 10., inputFile, answerFile)
+		if err != nil {
+			return nil, err
+		}
+		if verdict := failed(res); verdict != "" {
+			stderr, _ := ReadFirstBytes(stderrFile, 1024)
+			log.Entry(LevelError, "Trusted solution failed", LogFields{"seed": seed, "verdict": verdict, "stderr": stderr})
+			return nil, fmt.Errorf("trusted solution failed on seed %d: %s", seed, verdict)
+		}
+
+		res, err = run(suspect, suspect.exec.Execute, timeLimit, inputFile, outputFile)
+		if err != nil {
+			return nil, err
+		}
+		verdict := failed(res)
+		if verdict == "" {
+			verdict, result.Comment, err = runChecker(ctx, checker, checkerCmd, problemConf.MemoryLimit, workDir, inputFile, outputFile, answerFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if verdict == "AC" {
+			result.Comment = ""
+			continue
+		}
+		log.Entry(LevelInfo, "Mismatch found", LogFields{"seed": seed, "verdict": verdict, "cputime": res.CPUTime})
+
+		logrus.Infof("Suspect solution got %s on seed %d", verdict, seed)
+		result.Found = true
+		result.Seed = seed
+		result.Verdict = verdict
+		if err := result.save(saveDir, inputFile, answerFile, outputFile); err != nil {
+			return nil, fmt.Errorf("failed to save test: %v", err)
+		}
+		span.SetAttr("seed", seed)
+		break
+	}
+	span.SetAttr("found", result.Found)
+	return result, nil
+}
+
+// save copies the failing test into dir as stress-<seed>.{in,ans,out,seed}.
+func (r *StressResult) save(dir, input, answer, output string) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	name := filepath.Join(dir, fmt.Sprintf("stress-%d", r.Seed))
+	r.Input = name + ".in"
+	r.Answer = name + ".ans"
+	r.Output = name + ".out"
+	for src, dst := range map[string]string{input: r.Input, answer: r.Answer, output: r.Output} {
+		if err := shutil.CopyFile(src, dst, false); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(name+".seed", []byte(strconv.FormatInt(r.Seed, 10)+"\n"), 0644)
+}
@@ -0,0 +1,49 @@
+package pci15
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStressChecker(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "stress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	conf := &Config{Tmp: filepath.Join(tmp, "tmp")}
+
+	// the checker is valid when Stress goes on to compile, and fails as no
+	// generator is given
+	const valid = "no generator given"
+	tests := []struct {
+		name    string
+		yaml    string
+		checker string
+		err     string
+	}{
+		{"no checker", "case: []\n", "", valid},
+		{"builtin", "case: []\n", "!lcmp", valid},
+		{"overrides the problem", "checker:\n  source: chk.cpp\n  lang: cpp\n", "!diff", valid},
+		{"not builtin", "case: []\n", "wcmp", "unknown builtin checker wcmp"},
+		{"not builtin with problem checker", "checker:\n  source: chk.cpp\n  lang: cpp\n", "chk.cpp", "unknown builtin checker chk.cpp"},
+		{"unknown builtin", "case: []\n", "!wcmp", "unknown builtin checker !wcmp"},
+		{"unknown builtin of problem", "checker:\n  source: \"!wcmp\"\n", "", "unknown builtin checker !wcmp"},
+	}
+	for i, tt := range tests {
+		problem := filepath.Join(tmp, "problem", string('a'+rune(i)))
+		if err := os.MkdirAll(problem, 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(problem, "problem.yaml"), []byte(tt.yaml), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Stress(context.Background(), conf, problem, &StressOptions{Checker: tt.checker})
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.err)
+		}
+	}
+}
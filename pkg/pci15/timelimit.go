@@ -0,0 +1,203 @@
+package pci15
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+type TimeLimitOptions struct {
+	Runs       int     `json:"runs"`       // judgements of every solution
+	Multiplier float64 `json:"multiplier"` // of the slowest AC time
+	Round      uint64  `json:"round"`      // ms the suggestion is rounded up to
+	// Measure is the time limit in ms solutions are judged with, three times
+	// the one of the problem if 0. TLE solutions are measured up to it.
+	Measure uint64 `json:"measure"`
+}
+
+var DefaultTimeLimitOptions = TimeLimitOptions{
+	Runs:       3,
+	Multiplier: 2,
+	Round:      100,
+}
+
+// TimeLimitSuggestion is a time limit proposed from the CPU time of test
+// solutions. Times are in seconds, divided by the time ratio of the language
+// of the solution so they compare with the time limit of the problem.
+type TimeLimitSuggestion struct {
+	Current   uint64  `json:"current"`   // ms
+	Suggested uint64  `json:"suggested"` // ms
+	SlowestAC float32 `json:"slowest_ac"`
+	// FastestTLE is the time of the TLE solution whose slowest test is the
+	// fastest, zero if there is none
+	FastestTLE float32 `json:"fastest_tle,omitempty"`
+	// Margin is FastestTLE minus SlowestAC
+	Margin float32 `json:"margin,omitempty"`
+	// Safe is whether Suggested is below FastestTLE
+	Safe      bool                          `json:"safe"`
+	Languages map[string]*TimeLimitLanguage `json:"languages"`
+	Solutions []*TimeLimitSolution          `json:"sols"`
+}
+
+type TimeLimitLanguage struct {
+	TimeRatio  float32 `json:"timeratio"`
+	SlowestAC  float32 `json:"slowest_ac,omitempty"`
+	FastestTLE float32 `json:"fastest_tle,omitempty"`
+}
+
+type TimeLimitSolution struct {
+	Source   string `json:"source"`
+	Language string `json:"lang"`
+	Expected string `json:"expected"` // AC or TLE
+	// Tests are the times of every test, the slowest run for AC solutions
+	// and the fastest for TLE ones
+	Tests []float32 `json:"tests"`
+	Max   float32   `json:"max"`
+}
+
+// SuggestTimeLimit judges the test solutions expected to pass and to exceed
+// the time limit opts.Runs times each, and proposes the slowest AC time
+// times opts.Multiplier as the time limit. It is not safe when it does not
+// stay under every TLE solution.
+func SuggestTimeLimit(ctx context.Context, conf *Config, source string, opts *TimeLimitOptions) (*TimeLimitSuggestion, error) {
+	problemConf := &ProblemConfig{}
+	if err := loadYAML(filepath.Join(source, "problem.yaml"), problemConf); err != nil {
+		return nil, err
+	}
+	if problemConf.Type == ProblemTypeOutputOnly {
+		return nil, errors.New("output-only problems have no time limit")
+	}
+	if problemConf.TimeLimit == 0 {
+		problemConf.TimeLimit = problemConf.TimeLimitBK
+	}
+	if problemConf.TimeLimit == 0 {
+		problemConf.TimeLimit = 1000
+	}
+	runs := opts.Runs
+	if runs <= 0 {
+		runs = DefaultTimeLimitOptions.Runs
+	}
+	multiplier := opts.Multiplier
+	if multiplier <= 0 {
+		multiplier = DefaultTimeLimitOptions.Multiplier
+	}
+	round := opts.Round
+	if round == 0 {
+		round = DefaultTimeLimitOptions.Round
+	}
+	judgerConf := checkConf(conf, source)
+	judgerConf.TimeLimit = opts.Measure
+	if judgerConf.TimeLimit == 0 {
+		judgerConf.TimeLimit = problemConf.TimeLimit * 3
+	}
+
+	res := &TimeLimitSuggestion{
+		Current:   problemConf.TimeLimit,
+		Languages: make(map[string]*TimeLimitLanguage),
+	}
+	hasAC := false
+	for _, s := range problemConf.TestSolutions {
+		expected := ""
+		if s.Verdict == "TLE" || s.expects("TLE") {
+			expected = "TLE"
+		} else if s.expectsAC() {
+			expected = "AC"
+		} else {
+			continue
+		}
+		solution := s.solution(source)
+		lookup := solution
+		_, language, err := GetExecuteCommand(&lookup, conf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load language of %s: %v", solution.Source, err)
+		}
+		timeRatio := language.Execute.TimeRatio
+		if timeRatio <= 0 {
+			timeRatio = 1
+		}
+		sol := &TimeLimitSolution{
+			Source:   s.Source,
+			Language: solution.Language,
+			Expected: expected,
+		}
+		for i := 0; i < runs; i++ {
+			code := solution
+			runRes, err := Judge(ctx, judgerConf, &code, judgerConf.Problem)
+			if err != nil {
+				return nil, err
+			}
+			if runRes.Verdict == "CE" {
+				return nil, fmt.Errorf("%s does not compile", s.Source)
+			}
+			for j, detail := range runRes.Detail {
+				if j >= len(problemConf.Case) {
+					break
+				}
+				t := detail.ExeTime / timeRatio
+				if detail.Verdict == "TLE" {
+					// killed at the limit, it would have run longer
+					t = float32(judgerConf.TimeLimit) / 1000.
+				}
+				if i == 0 {
+					sol.Tests = append(sol.Tests, t)
+				} else if expected == "AC" && t > sol.Tests[j] || expected == "TLE" && t < sol.Tests[j] {
+					sol.Tests[j] = t
+				}
+			}
+		}
+		for _, t := range sol.Tests {
+			if t > sol.Max {
+				sol.Max = t
+			}
+		}
+		logrus.Infof("Measured %s (%s): %.3fs", s.Source, expected, sol.Max)
+		res.Solutions = append(res.Solutions, sol)
+
+		lang, ok := res.Languages[solution.Language]
+		if !ok {
+			lang = &TimeLimitLanguage{TimeRatio: timeRatio}
+			res.Languages[solution.Language] = lang
+		}
+		if expected == "AC" {
+			hasAC = true
+			if sol.Max > res.SlowestAC {
+				res.SlowestAC = sol.Max
+			}
+			if sol.Max > lang.SlowestAC {
+				lang.SlowestAC = sol.Max
+			}
+		} else {
+			if res.FastestTLE == 0 || sol.Max < res.FastestTLE {
+				res.FastestTLE = sol.Max
+			}
+			if lang.FastestTLE == 0 || sol.Max < lang.FastestTLE {
+				lang.FastestTLE = sol.Max
+			}
+		}
+	}
+	if !hasAC {
+		return nil, errors.New("no test solution is expected to get AC")
+	}
+
+	res.suggest(multiplier, round)
+	return res, nil
+}
+
+// suggest sets the suggested time limit from SlowestAC, multiplied by
+// multiplier and rounded up to round ms, and tells whether it stays under
+// FastestTLE.
+func (res *TimeLimitSuggestion) suggest(multiplier float64, round uint64) {
+	// times are float32, the error of 0.3s must not be rounded up to a ms
+	ms := float64(res.SlowestAC) * multiplier * 1000.
+	suggested := uint64(math.Ceil(ms - ms*1e-6))
+	res.Suggested = (suggested + round - 1) / round * round
+	res.Safe = true
+	if res.FastestTLE > 0 {
+		res.Margin = res.FastestTLE - res.SlowestAC
+		res.Safe = float32(res.Suggested)/1000. < res.FastestTLE
+	}
+}
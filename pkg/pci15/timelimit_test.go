@@ -0,0 +1,33 @@
+package pci15
+
+import "testing"
+
+func TestSuggestTimeLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		slowestAC  float32
+		fastestTLE float32
+		multiplier float64
+		round      uint64
+		suggested  uint64
+		margin     float32
+		safe       bool
+	}{
+		{"rounded up", 0.31, 0, 2, 100, 700, 0, true},
+		{"exact", 0.3, 0, 2, 100, 600, 0, true},
+		{"not rounded", 0.3, 0, 2, 1, 600, 0, true},
+		{"partial ms", 0.3005, 0, 1, 1, 301, 0, true},
+		{"no AC time", 0, 0, 2, 100, 0, 0, true},
+		{"under TLE", 0.25, 1, 2, 100, 500, 0.75, true},
+		{"at TLE", 0.25, 0.5, 2, 100, 500, 0.25, false},
+		{"over TLE", 0.25, 0.75, 3, 500, 1000, 0.5, false},
+	}
+	for _, tt := range tests {
+		res := &TimeLimitSuggestion{SlowestAC: tt.slowestAC, FastestTLE: tt.fastestTLE}
+		res.suggest(tt.multiplier, tt.round)
+		if res.Suggested != tt.suggested || res.Margin != tt.margin || res.Safe != tt.safe {
+			t.Errorf("%s: got %d ms, margin %g, safe %v, want %d ms, margin %g, safe %v",
+				tt.name, res.Suggested, res.Margin, res.Safe, tt.suggested, tt.margin, tt.safe)
+		}
+	}
+}
@@ -7,18 +7,31 @@ import (
 )
 
 type ProblemConfig struct {
-	Version     int         `json:"version"`
-	TimeLimit   uint64      `json:"timelimit"`
-	TimeLimitBK uint64      `json:"time"`
-	MemoryLimit uint64      `json:"memorylimit"`
-	Name        string      `json:"name,omitempty"`
-	Template    string      `json:"template"`
-	Checker     *SourceCode `json:"checker"`
-	Interactor  *SourceCode `json:"interactor,omitempty"`
-	ExtraFile   []string    `json:"additionalLibrary,omitempty"`
-	Case        []TestCase  `json:"case"`
+	Version     int    `json:"version"`
+	Type        string `json:"type,omitempty"` // ProblemTypeOutputOnly, ProblemTypeCommunication, or a program judged on the tests
+	TimeLimit   uint64 `json:"timelimit"`
+	TimeLimitBK uint64 `json:"time"`
+	MemoryLimit uint64 `json:"memorylimit"`
+	Name        string `json:"name,omitempty"`
+	Template    string `json:"template"`
+	// Grader are the files compiled with submissions, by language
+	Grader     map[string]*Grader `json:"grader,omitempty"`
+	Checker    *SourceCode        `json:"checker"`
+	Interactor *SourceCode        `json:"interactor,omitempty"`
+	// Communication is the run graph of communication problems
+	Communication *Communication `json:"communication,omitempty"`
+	Validator     *SourceCode    `json:"validator,omitempty"`
+	// AnswerGenerator is the reference solution, producing answers of hacks
+	AnswerGenerator *SourceCode `json:"main_ac,omitempty"`
+	ExtraFile       []string    `json:"additionalLibrary,omitempty"`
+	Case            []TestCase  `json:"case"`
+
+	Feedback FeedbackPolicy `json:"feedback,omitempty"`
 
 	TestSolutions []*TestSolution `json:"test_solution,omitempty"`
+
+	CheckerTests    []*CheckerTest    `json:"checker_test,omitempty"`
+	InteractorTests []*InteractorTest `json:"interactor_test,omitempty"`
 }
 
 type SourceCode struct {
@@ -26,11 +39,26 @@ type SourceCode struct {
 	Language      string         `json:"lang"`
 	Executable    string         `json:"-"`
 	CompileResult *ExecuteResult `json:"-"`
+	// Extra are more sources compiled with Source, like a grader
+	Extra []string `json:"-"`
+	// Variables override the language variables once Source is named
+	Variables map[string]string `json:"-"`
+	// Project is set when Source is the entry of an unpacked archive
+	Project bool `json:"-"`
 }
 
 type TestSolution struct {
 	SourceCode
+	// ExpectedVerdict lists the verdicts every test may get
 	ExpectedVerdict []string `json:"expected_verdict"`
+
+	Verdict  string                `json:"verdict,omitempty"` // of the whole judgement
+	Score    *int                  `json:"score,omitempty"`
+	MinScore *int                  `json:"min_score,omitempty"`
+	MaxScore *int                  `json:"max_score,omitempty"`
+	Fails    bool                  `json:"fails,omitempty"` // some test is not AC
+	Subtasks []*SubtaskExpectation `json:"subtasks,omitempty"`
+	Tests    []*TestExpectation    `json:"tests,omitempty"`
 }
 
 type TestCase struct {
@@ -41,6 +69,9 @@ type TestCase struct {
 	Example      bool     `json:"example"`
 	TimeLimit    uint64   `json:"time,omitempty"`
 	MemoryLimit  uint64   `json:"memoryLimit,omitempty"`
+	// Submit is the name of the output of the test in submissions to
+	// output-only problems, the input with the .out extension if empty
+	Submit string `json:"submit,omitempty"`
 }
 
 type Language struct {
@@ -57,13 +88,17 @@ type Language struct {
 	Source     string `json:"source"`
 	Executable string `json:"executable"`
 	Compile    *struct {
-		Cmd       []string `json:"args"`
+		Cmd []string `json:"args"`
+		// MultiCmd compiles several files, given by the {sources} argument
+		MultiCmd  []string `json:"multiargs"`
 		TimeLimit float32  `json:"timelimit"`
 	} `json:"compile"`
 	Execute *struct {
 		Cmd       []string `json:"cmd"`
 		TimeRatio float32  `json:"timeratio"`
 	}
+	// Project are the ways archives are built, tried in order
+	Project []*Project `json:"project"`
 }
 
 type CompileResult struct {
@@ -0,0 +1,179 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	FormatChrome = "chrome"
+	FormatOTLP   = "otlp"
+)
+
+type chromeEvent struct {
+	Name string                 `json:"name"`
+	Ph   string                 `json:"ph"`
+	Ts   int64                  `json:"ts"`
+	Dur  int64                  `json:"dur"`
+	Pid  int                    `json:"pid"`
+	Tid  int                    `json:"tid"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+func spanEnd(s *Span) time.Time {
+	if s.End.IsZero() {
+		return time.Now()
+	}
+	return s.End
+}
+
+// WriteChrome writes the trace in the Chrome trace-event format, to be loaded
+// into chrome://tracing or Perfetto.
+func (t *Trace) WriteChrome(w io.Writer) error {
+	spans := t.Spans()
+	events := make([]*chromeEvent, 0, len(spans))
+	for _, s := range spans {
+		events = append(events, &chromeEvent{
+			Name: s.Name,
+			Ph:   "X",
+			Ts:   s.Start.UnixNano() / 1000,
+			Dur:  spanEnd(s).Sub(s.Start).Nanoseconds() / 1000,
+			Pid:  1,
+			Tid:  s.Lane,
+			Args: s.Attrs,
+		})
+	}
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	})
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []otlpAttr `json:"attributes,omitempty"`
+}
+
+func otlpAttrValue(v interface{}) otlpValue {
+	switch val := v.(type) {
+	case string:
+		return otlpValue{StringValue: &val}
+	case bool:
+		return otlpValue{BoolValue: &val}
+	case int:
+		s := strconv.FormatInt(int64(val), 10)
+		return otlpValue{IntValue: &s}
+	case int32:
+		s := strconv.FormatInt(int64(val), 10)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(val, 10)
+		return otlpValue{IntValue: &s}
+	case uint64:
+		s := strconv.FormatUint(val, 10)
+		return otlpValue{IntValue: &s}
+	case float32:
+		f := float64(val)
+		return otlpValue{DoubleValue: &f}
+	case float64:
+		return otlpValue{DoubleValue: &val}
+	default:
+		s := fmt.Sprint(val)
+		return otlpValue{StringValue: &s}
+	}
+}
+
+func otlpAttrs(attrs map[string]interface{}) []otlpAttr {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ret := make([]otlpAttr, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, otlpAttr{Key: k, Value: otlpAttrValue(attrs[k])})
+	}
+	return ret
+}
+
+// WriteOTLP writes the trace as an OTLP/JSON ExportTraceServiceRequest, which
+// OpenTelemetry collectors accept on their HTTP receiver or as a file.
+func (t *Trace) WriteOTLP(w io.Writer, service string) error {
+	spans := t.Spans()
+	out := make([]*otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		attrs := map[string]interface{}{"lane": s.Lane}
+		for k, v := range s.Attrs {
+			attrs[k] = v
+		}
+		out = append(out, &otlpSpan{
+			TraceID:           t.ID,
+			SpanID:            s.ID,
+			ParentSpanID:      s.Parent,
+			Name:              s.Name,
+			Kind:              1,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(spanEnd(s).UnixNano(), 10),
+			Attributes:        otlpAttrs(attrs),
+		})
+	}
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttrs(map[string]interface{}{"service.name": service}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "pcijudger"},
+						"spans": out,
+					},
+				},
+			},
+		},
+	})
+}
+
+// WriteFile writes the trace to path in format, chrome or otlp.
+func (t *Trace) WriteFile(path, format, service string) error {
+	fp, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	switch format {
+	case FormatChrome, "":
+		err = t.WriteChrome(fp)
+	case FormatOTLP:
+		err = t.WriteOTLP(fp, service)
+	default:
+		err = fmt.Errorf("unknown trace format %s", format)
+	}
+	if err != nil {
+		return err
+	}
+	return fp.Close()
+}
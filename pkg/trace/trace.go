@@ -0,0 +1,136 @@
+// Package trace records nested, timed spans of one judgement. The trace
+// travels in the context, functions that get a context without one record
+// nothing, so tracing costs nothing unless asked for.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+type Span struct {
+	ID     string                 `json:"id"`
+	Parent string                 `json:"parent,omitempty"`
+	Name   string                 `json:"name"`
+	Lane   int                    `json:"lane"`
+	Start  time.Time              `json:"start"`
+	End    time.Time              `json:"end"`
+	Attrs  map[string]interface{} `json:"attrs,omitempty"`
+	trace  *Trace
+}
+
+type Trace struct {
+	ID    string
+	mut   sync.Mutex
+	spans []*Span
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func New() *Trace {
+	return &Trace{
+		ID: randomID(16),
+	}
+}
+
+type traceKey struct{}
+type spanKey struct{}
+
+// NewContext returns a context recording spans into t.
+func NewContext(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+func FromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// Start opens a span named name, child of the span in ctx if any, and returns
+// a context carrying it. The span is nil when ctx has no trace, all methods of
+// Span accept a nil receiver.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	t := FromContext(ctx)
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		ID:    randomID(8),
+		Name:  name,
+		Start: time.Now(),
+		trace: t,
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.Parent = parent.ID
+		s.Lane = parent.Lane
+	}
+	t.mut.Lock()
+	t.spans = append(t.spans, s)
+	t.mut.Unlock()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetLane puts the span and spans started under it afterwards on their own
+// lane, a thread in the Chrome trace viewer. Lane 0 is the main one.
+func (s *Span) SetLane(lane int) {
+	if s == nil {
+		return
+	}
+	s.trace.mut.Lock()
+	defer s.trace.mut.Unlock()
+	s.Lane = lane
+}
+
+func (s *Span) SetAttr(key string, val interface{}) {
+	if s == nil {
+		return
+	}
+	s.trace.mut.Lock()
+	defer s.trace.mut.Unlock()
+	if s.Attrs == nil {
+		s.Attrs = make(map[string]interface{})
+	}
+	s.Attrs[key] = val
+}
+
+// SetError records err, if not nil, as the error attribute.
+func (s *Span) SetError(err error) {
+	if err != nil {
+		s.SetAttr("error", err.Error())
+	}
+}
+
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.trace.mut.Lock()
+	defer s.trace.mut.Unlock()
+	s.End = time.Now()
+}
+
+// Spans returns a copy of the spans recorded so far, in the order they were
+// started. Spans still open have a zero End.
+func (t *Trace) Spans() []*Span {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	ret := make([]*Span, len(t.spans))
+	for i, s := range t.spans {
+		cp := *s
+		if s.Attrs != nil {
+			cp.Attrs = make(map[string]interface{}, len(s.Attrs))
+			for k, v := range s.Attrs {
+				cp.Attrs[k] = v
+			}
+		}
+		ret[i] = &cp
+	}
+	return ret
+}
@@ -0,0 +1,125 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStart(t *testing.T) {
+	if ctx, s := Start(context.Background(), "judge"); s != nil || FromContext(ctx) != nil {
+		t.Errorf("span recorded without a trace")
+	}
+
+	tr := New()
+	ctx, judge := Start(NewContext(context.Background(), tr), "judge")
+	_, compile := Start(ctx, "compile")
+	compile.Finish()
+	testCtx, test := Start(ctx, "test")
+	test.SetLane(2)
+	_, run := Start(testCtx, "run")
+	run.SetError(errors.New("killed"))
+	run.SetError(nil)
+	judge.Finish()
+
+	tests := []struct {
+		name   string
+		parent string
+		lane   int
+		attrs  map[string]interface{}
+		open   bool
+	}{
+		{"judge", "", 0, nil, false},
+		{"compile", judge.ID, 0, nil, false},
+		{"test", judge.ID, 2, nil, true},
+		{"run", test.ID, 2, map[string]interface{}{"error": "killed"}, true},
+	}
+	spans := tr.Spans()
+	if len(spans) != len(tests) {
+		t.Fatalf("got %d spans, want %d", len(spans), len(tests))
+	}
+	for i, tt := range tests {
+		s := spans[i]
+		if s.Name != tt.name || s.Parent != tt.parent || s.Lane != tt.lane || s.End.IsZero() != tt.open {
+			t.Errorf("%s: got %s, parent %q, lane %d, open %v", tt.name, s.Name, s.Parent, s.Lane, s.End.IsZero())
+		}
+		if len(s.Attrs) != len(tt.attrs) || (tt.attrs != nil && s.Attrs["error"] != tt.attrs["error"]) {
+			t.Errorf("%s: got attrs %v, want %v", tt.name, s.Attrs, tt.attrs)
+		}
+	}
+}
+
+func TestOTLPAttrValue(t *testing.T) {
+	tests := []struct {
+		val  interface{}
+		want string
+	}{
+		{"judge", `{"stringValue":"judge"}`},
+		{true, `{"boolValue":true}`},
+		{3, `{"intValue":"3"}`},
+		{int64(-4), `{"intValue":"-4"}`},
+		{uint64(1) << 63, `{"intValue":"9223372036854775808"}`},
+		{float32(0.5), `{"doubleValue":0.5}`},
+		{1.25, `{"doubleValue":1.25}`},
+		{[]string{"a"}, `{"stringValue":"[a]"}`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(otlpAttrValue(tt.val))
+		if err != nil {
+			t.Errorf("%v: %v", tt.val, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	start := time.Unix(1556712000, 0)
+	tr := &Trace{ID: "0123456789abcdef0123456789abcdef"}
+	tr.spans = []*Span{
+		{ID: "0000000000000001", Name: "judge", Start: start, End: start.Add(2 * time.Second), trace: tr},
+		{ID: "0000000000000002", Parent: "0000000000000001", Name: "test", Lane: 1, Start: start.Add(time.Millisecond), End: start.Add(1500 * time.Microsecond),
+			Attrs: map[string]interface{}{"test": 1}, trace: tr},
+	}
+
+	tests := []struct {
+		name  string
+		write func(*bytes.Buffer) error
+		want  string
+	}{
+		{
+			name:  "chrome",
+			write: func(buf *bytes.Buffer) error { return tr.WriteChrome(buf) },
+			want: `{"displayTimeUnit":"ms","traceEvents":[` +
+				`{"name":"judge","ph":"X","ts":1556712000000000,"dur":2000000,"pid":1,"tid":0},` +
+				`{"name":"test","ph":"X","ts":1556712000001000,"dur":500,"pid":1,"tid":1,"args":{"test":1}}]}` + "\n",
+		},
+		{
+			name:  "otlp",
+			write: func(buf *bytes.Buffer) error { return tr.WriteOTLP(buf, "judger") },
+			want: `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"judger"}}]},` +
+				`"scopeSpans":[{"scope":{"name":"pcijudger"},"spans":[` +
+				`{"traceId":"0123456789abcdef0123456789abcdef","spanId":"0000000000000001","name":"judge","kind":1,` +
+				`"startTimeUnixNano":"1556712000000000000","endTimeUnixNano":"1556712002000000000",` +
+				`"attributes":[{"key":"lane","value":{"intValue":"0"}}]},` +
+				`{"traceId":"0123456789abcdef0123456789abcdef","spanId":"0000000000000002","parentSpanId":"0000000000000001","name":"test","kind":1,` +
+				`"startTimeUnixNano":"1556712000001000000","endTimeUnixNano":"1556712000001500000",` +
+				`"attributes":[{"key":"lane","value":{"intValue":"1"}},{"key":"test","value":{"intValue":"1"}}]}]}]}]}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := tt.write(&buf); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, buf.String(), tt.want)
+		}
+	}
+}